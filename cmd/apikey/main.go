@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go-https-server/internal/auth"
+	"go-https-server/internal/config"
+	"go-https-server/internal/database"
+	"go-https-server/internal/logger"
+	"go-https-server/internal/models"
+	"go-https-server/internal/store"
+)
+
+const usage = `usage: apikey <command> [flags]
+
+commands:
+  create -name NAME [-scopes read,station:write]   create a key and print it once
+  list                                             list all keys
+  revoke -id ID                                    revoke a key
+`
+
+func main() {
+	logger.Init()
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Fatalf("could not load config: %v", err)
+	}
+
+	db, err := database.New(cfg.DatabaseURL, database.Pool{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+	})
+	if err != nil {
+		log.Fatalf("could not connect to database: %v", err)
+	}
+	defer db.Close()
+
+	if err := database.Migrate(db); err != nil {
+		log.Fatalf("could not migrate database: %v", err)
+	}
+
+	s := store.New(db, store.Timeouts{Read: cfg.DBReadTimeout, Write: cfg.DBWriteTimeout})
+
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "create":
+		err = createKey(s, args)
+	case "list":
+		err = listKeys(s)
+	case "revoke":
+		err = revokeKey(s, args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func createKey(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	name := fs.String("name", "", "name identifying the key holder")
+	scopes := fs.String("scopes", auth.ScopeRead, "comma separated scopes: read, station:write")
+	fs.Parse(args)
+
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+
+	var scopeList []string
+	for _, scope := range strings.Split(*scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopeList = append(scopeList, scope)
+		}
+	}
+	if err := auth.ValidateScopes(scopeList); err != nil {
+		return err
+	}
+
+	key, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		return fmt.Errorf("could not generate API key: %w", err)
+	}
+
+	apiKey := &models.APIKey{
+		Name:    *name,
+		Prefix:  prefix,
+		KeyHash: auth.HashAPIKey(key),
+		Scopes:  scopeList,
+	}
+	if err := s.CreateAPIKey(context.Background(), apiKey); err != nil {
+		return fmt.Errorf("could not create API key: %w", err)
+	}
+
+	log.Printf("created API key %d for %s with scopes %v", apiKey.ID, apiKey.Name, scopeList)
+	fmt.Println("Store this key now, it cannot be shown again:")
+	fmt.Println(key)
+	return nil
+}
+
+func listKeys(s *store.Store) error {
+	keys, err := s.GetAPIKeys(context.Background())
+	if err != nil {
+		return fmt.Errorf("could not list API keys: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tSCOPES\tCREATED\tLAST USED\tREVOKED")
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			k.ID, k.Name, k.Prefix, strings.Join(k.Scopes, ","),
+			k.CreatedAt.Format(time.RFC3339), formatTime(k.LastUsedAt), formatTime(k.RevokedAt))
+	}
+	return tw.Flush()
+}
+
+func revokeKey(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("revoke", flag.ExitOnError)
+	id := fs.Int("id", 0, "ID of the key to revoke")
+	fs.Parse(args)
+
+	if *id == 0 {
+		return fmt.Errorf("-id is required")
+	}
+	if err := s.RevokeAPIKey(context.Background(), *id); err != nil {
+		return fmt.Errorf("could not revoke API key %d: %w", *id, err)
+	}
+
+	log.Printf("revoked API key %d", *id)
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"go-https-server/internal/auth"
+	"go-https-server/internal/config"
+	"go-https-server/internal/database"
+	"go-https-server/internal/logger"
+	"go-https-server/internal/models"
+	"go-https-server/internal/store"
+)
+
+func main() {
+	logger.Init()
+
+	username := flag.String("username", "", "username of the new user")
+	roleName := flag.String("role", string(auth.RoleViewer), "role of the new user: viewer, editor or admin")
+	tags := flag.String("tags", "", "comma separated station tags the user is restricted to (editors only)")
+	flag.Parse()
+
+	if *username == "" {
+		log.Fatal("-username is required")
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		log.Fatalf("could not read password: %v", err)
+	}
+
+	role, err := auth.ParseRole(*roleName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var tagScopes []string
+	for _, tag := range strings.Split(*tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tagScopes = append(tagScopes, tag)
+		}
+	}
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Fatalf("could not load config: %v", err)
+	}
+
+	db, err := database.New(cfg.DatabaseURL, database.Pool{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+	})
+	if err != nil {
+		log.Fatalf("could not connect to database: %v", err)
+	}
+	defer db.Close()
+
+	if err := database.Migrate(db); err != nil {
+		log.Fatalf("could not migrate database: %v", err)
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		log.Fatalf("could not hash password: %v", err)
+	}
+
+	user := &models.User{Username: *username, PasswordHash: hash, Role: string(role), TagScopes: tagScopes}
+	if err := store.New(db, store.Timeouts{Read: cfg.DBReadTimeout, Write: cfg.DBWriteTimeout}).CreateUser(context.Background(), user); err != nil {
+		log.Fatalf("could not create user %s: %v", *username, err)
+	}
+
+	log.Printf("created %s user %s with id %d", user.Role, user.Username, user.ID)
+}
+
+// readPassword returns the new user's password from CREATE_USER_PASSWORD or,
+// if that is unset, the first line of standard input. It is never taken from a
+// flag, where it would show up in ps and the shell history.
+func readPassword() (string, error) {
+	if password := os.Getenv("CREATE_USER_PASSWORD"); password != "" {
+		return password, nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password given on standard input or in CREATE_USER_PASSWORD")
+	}
+	return password, nil
+}
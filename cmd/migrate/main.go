@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"go-https-server/internal/config"
+	"go-https-server/internal/database"
+	"go-https-server/internal/logger"
+)
+
+const usage = `usage: migrate <command> [args]
+
+commands:
+  up          apply all pending migrations
+  down N      revert the N most recently applied migrations
+  status      list migrations and when they were applied
+  force V     record migrations up to V as applied without running them
+`
+
+func main() {
+	logger.Init()
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Fatalf("could not load config: %v", err)
+	}
+
+	db, err := database.New(cfg.DatabaseURL, database.Pool{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+	})
+	if err != nil {
+		log.Fatalf("could not connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	switch cmd, args := os.Args[1], os.Args[2:]; {
+	case cmd == "up" && len(args) == 0:
+		err = up(ctx, db)
+	case cmd == "down" && len(args) == 1:
+		err = down(ctx, db, args[0])
+	case cmd == "status" && len(args) == 0:
+		err = status(ctx, db)
+	case cmd == "force" && len(args) == 1:
+		err = force(ctx, db, args[0])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func up(ctx context.Context, db *sql.DB) error {
+	applied, err := database.MigrateUp(ctx, db)
+	for _, m := range applied {
+		log.Printf("applied %04d_%s", m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		log.Printf("schema is up to date at version %d", database.SchemaVersion)
+	}
+	return nil
+}
+
+func down(ctx context.Context, db *sql.DB, arg string) error {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		return fmt.Errorf("N must be a positive number, got %q", arg)
+	}
+
+	reverted, err := database.MigrateDown(ctx, db, n)
+	for _, m := range reverted {
+		log.Printf("reverted %04d_%s", m.Version, m.Name)
+	}
+	return err
+}
+
+func status(ctx context.Context, db *sql.DB) error {
+	statuses, err := database.MigrationStatuses(ctx, db)
+	if err != nil {
+		return fmt.Errorf("could not read migration status: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+	for _, s := range statuses {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	return tw.Flush()
+}
+
+func force(ctx context.Context, db *sql.DB, arg string) error {
+	version, err := strconv.Atoi(arg)
+	if err != nil {
+		return fmt.Errorf("V must be a number, got %q", arg)
+	}
+	if err := database.ForceVersion(ctx, db, version); err != nil {
+		return fmt.Errorf("could not force version %d: %w", version, err)
+	}
+
+	log.Printf("forced schema version to %d", version)
+	return nil
+}
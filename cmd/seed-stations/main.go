@@ -1,14 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"go-https-server/internal/auth"
 	"go-https-server/internal/config"
 	"go-https-server/internal/database"
 	"go-https-server/internal/logger"
@@ -39,41 +48,91 @@ type Stop struct {
 	Long   string `json:"long"`
 }
 
+var (
+	// registry holds the job's metrics, which are pushed to a Pushgateway when the run ends.
+	registry = prometheus.NewRegistry()
+
+	stopsProcessed = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: "odbus",
+		Name:      "seed_stations_stops_total",
+		Help:      "Stops processed by seed-stations by result (created or failed).",
+	}, []string{"result"})
+)
+
+// stationCreator persists seeded stations, either directly through the store
+// or through the HTTP API.
+type stationCreator interface {
+	CreateStation(ctx context.Context, st *models.Station) error
+}
+
 func main() {
 	logger.Init()
 
-	cfg, err := config.Load()
-	if err != nil {
-		log.Fatalf("could not load config: %v", err)
+	apiURL := flag.String("api-url", "", "base URL of the server, e.g. https://localhost:8443; seeds through the HTTP API instead of the database")
+	apiKey := flag.String("api-key", "", "API key with the station:write scope, used with -api-url (defaults to $API_KEY)")
+	pushgateway := flag.String("pushgateway", "", "URL of a Prometheus Pushgateway to push run metrics to")
+	flag.Parse()
+	// Read after parsing rather than as the flag default, so -help does not print the key.
+	if *apiKey == "" {
+		*apiKey = os.Getenv("API_KEY")
 	}
 
-	db, err := database.New(cfg.DatabaseURL)
-	if err != nil {
-		log.Fatalf("could not connect to database: %v", err)
-	}
-	defer db.Close()
+	var s stationCreator
+	if *apiURL != "" {
+		if *apiKey == "" {
+			log.Fatal("-api-key or API_KEY is required with -api-url")
+		}
+		s = &apiClient{baseURL: strings.TrimRight(*apiURL, "/"), key: *apiKey, http: &http.Client{Timeout: 10 * time.Second}}
+		log.Printf("seeding through %s", *apiURL)
+	} else {
+		cfg, err := config.Load(nil)
+		if err != nil {
+			log.Fatalf("could not load config: %v", err)
+		}
 
-	if err := db.Ping(); err != nil {
-		log.Fatalf("could not ping database: %v", err)
-	}
+		db, err := database.New(cfg.DatabaseURL, database.Pool{
+			MaxOpenConns:    cfg.DBMaxOpenConns,
+			MaxIdleConns:    cfg.DBMaxIdleConns,
+			ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		})
+		if err != nil {
+			log.Fatalf("could not connect to database: %v", err)
+		}
+		defer db.Close()
 
-	log.Println("database connection successful")
+		if err := db.Ping(); err != nil {
+			log.Fatalf("could not ping database: %v", err)
+		}
 
-	s := store.New(db)
+		log.Println("database connection successful")
+
+		s = store.New(db, store.Timeouts{Read: cfg.DBReadTimeout, Write: cfg.DBWriteTimeout})
+	}
 
 	route := "63X"
 	directions := []string{"outbound", "inbound"}
 
 	for _, direction := range directions {
 		if err := fetchAndSeedStations(s, route, direction); err != nil {
+			pushMetrics(*pushgateway)
 			log.Fatalf("could not seed stations for route %s %s: %v", route, direction, err)
 		}
 	}
 
+	pushMetrics(*pushgateway)
 	log.Println("successfully seeded all stations")
 }
 
-func fetchAndSeedStations(s *store.Store, route string, direction string) error {
+func pushMetrics(url string) {
+	if url == "" {
+		return
+	}
+	if err := push.New(url, "seed_stations").Gatherer(registry).Push(); err != nil {
+		log.Printf("could not push metrics to %s: %v", url, err)
+	}
+}
+
+func fetchAndSeedStations(s stationCreator, route string, direction string) error {
 	log.Printf("fetching stations for route %s, direction %s", route, direction)
 
 	routeStopURL := fmt.Sprintf(kmbRouteStopAPI, route, direction)
@@ -101,7 +160,10 @@ func fetchAndSeedStations(s *store.Store, route string, direction string) error
 
 	for _, routeStop := range routeStopResponse.Data {
 		if err := processStop(s, routeStop.Stop, route, direction); err != nil {
+			stopsProcessed.WithLabelValues("failed").Inc()
 			log.Printf("could not process stop %s: %v. skipping.", routeStop.Stop, err)
+		} else {
+			stopsProcessed.WithLabelValues("created").Inc()
 		}
 		// Add a small delay to avoid hitting API rate limits.
 		time.Sleep(100 * time.Millisecond)
@@ -110,7 +172,7 @@ func fetchAndSeedStations(s *store.Store, route string, direction string) error
 	return nil
 }
 
-func processStop(s *store.Store, stopID, route, direction string) error {
+func processStop(s stationCreator, stopID, route, direction string) error {
 	stopURL := fmt.Sprintf(kmbStopAPI, stopID)
 	resp, err := http.Get(stopURL)
 	if err != nil {
@@ -149,10 +211,59 @@ func processStop(s *store.Store, stopID, route, direction string) error {
 		Tags:      []string{"kmb", route, direction},
 	}
 
-	if err := s.CreateStation(station); err != nil {
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Username: "seed-stations"})
+	if err := s.CreateStation(ctx, station); err != nil {
 		return fmt.Errorf("could not create station '%s': %w", station.Name, err)
 	}
 
 	log.Printf("successfully inserted station: %s", station.Name)
 	return nil
 }
+
+// apiClient creates stations through /api/station/create, authenticating with an API key.
+type apiClient struct {
+	baseURL string
+	key     string
+	http    *http.Client
+}
+
+type apiResponse struct {
+	Error   bool            `json:"error"`
+	Message string          `json:"message"`
+	Code    int             `json:"code"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func (c *apiClient) CreateStation(ctx context.Context, st *models.Station) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"name":      st.Name,
+		"latitude":  st.Latitude,
+		"longitude": st.Longitude,
+		"tags":      st.Tags,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/station/create", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("token", c.key)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var apiResp apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return fmt.Errorf("could not decode response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || apiResp.Error {
+		return fmt.Errorf("server rejected station (status %d, code %d): %s", resp.StatusCode, apiResp.Code, apiResp.Message)
+	}
+	return json.Unmarshal(apiResp.Data, st)
+}
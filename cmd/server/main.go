@@ -1,55 +1,234 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"go-https-server/internal/auth"
 	"go-https-server/internal/config"
 	"go-https-server/internal/database"
 	"go-https-server/internal/handler"
+	"go-https-server/internal/kml"
 	"go-https-server/internal/logger"
+	"go-https-server/internal/metrics"
 	"go-https-server/internal/router"
 	"go-https-server/internal/server"
 	"go-https-server/internal/store"
+	"go-https-server/internal/tracing"
 )
 
+const blockedSignsKMZ = "blocked_sign.kmz"
+
 func main() {
 	logger.Init()
 
-	cfg, err := config.Load()
+	cfg, err := config.Load(os.Args[1:])
 	if err != nil {
 		log.Fatalf("could not load config: %v", err)
 	}
 
-	db, err := database.New(cfg.DatabaseURL)
+	if cfg.PrintConfig {
+		if err := cfg.WriteRedacted(os.Stdout); err != nil {
+			log.Fatalf("could not print config: %v", err)
+		}
+		return
+	}
+	if err := cfg.ValidateServer(); err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+
+	if err := logger.SetLevel(cfg.LogLevel); err != nil {
+		log.Fatalf("invalid LOG_LEVEL %q: %v", cfg.LogLevel, err)
+	}
+
+	if err := run(cfg); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("server stopped")
+}
+
+func run(cfg *config.Config) error {
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		ServiceName:  "odbus-server",
+		Exporter:     cfg.TracingExporter,
+		File:         cfg.TracingFile,
+		OTLPEndpoint: cfg.TracingOTLPEndpoint,
+		SampleRatio:  cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		return fmt.Errorf("could not configure tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("could not flush traces: %v", err)
+		}
+	}()
+
+	db, err := database.New(cfg.DatabaseURL, database.Pool{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+	})
 	if err != nil {
-		log.Fatalf("could not connect to database: %v", err)
+		return fmt.Errorf("could not connect to database: %w", err)
 	}
-	defer db.Close()
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("could not close database: %v", err)
+		}
+		log.Println("database connections closed")
+	}()
 
 	if err := db.Ping(); err != nil {
-		log.Fatalf("could not ping database: %v", err)
+		return fmt.Errorf("could not ping database: %w", err)
 	}
 
 	log.Println("database connection successful")
 
+	if err := metrics.RegisterDB(db, "odbus"); err != nil {
+		return fmt.Errorf("could not register database metrics: %w", err)
+	}
+
 	if err := database.Migrate(db); err != nil {
-		log.Fatalf("could not migrate database: %v", err)
+		return fmt.Errorf("could not migrate database: %w", err)
 	}
 	log.Println("database migration successful")
 
-	if err := database.SeedBlockedSigns(db, "blocked_sign.kmz"); err != nil {
-		log.Fatalf("could not seed blocked signs data: %v", err)
+	kmzOpts := kml.KMZOptions{MergeAll: cfg.KMZMergeAll}
+	if err := database.SeedBlockedSigns(context.Background(), db, blockedSignsKMZ, kmzOpts); err != nil {
+		return fmt.Errorf("could not seed blocked signs data: %w", err)
+	}
+
+	tokens, err := newTokens(cfg)
+	if err != nil {
+		return fmt.Errorf("could not configure JWT authentication: %w", err)
 	}
 
-	s := store.New(db)
+	s := store.New(db, store.Timeouts{Read: cfg.DBReadTimeout, Write: cfg.DBWriteTimeout})
 	apiHandler := handler.NewApiHandler(s)
+	authHandler := handler.NewAuthHandler(s, tokens)
+	seedHandler := handler.NewSeedHandler(db, blockedSignsKMZ, kmzOpts)
+	healthHandler := handler.NewHealthHandler(db)
+
+	inFlight := server.NewInFlight()
+	r := router.New(apiHandler, authHandler, seedHandler, healthHandler, tokens, s, router.CORSConfig{
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedMethods:   cfg.CORSAllowedMethods,
+		AllowedHeaders:   cfg.CORSAllowedHeaders,
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           cfg.CORSMaxAge,
+	})
+	timeouts := server.Timeouts{Read: cfg.ServerReadTimeout, Write: cfg.ServerWriteTimeout, Idle: cfg.ServerIdleTimeout}
+	srv := server.New(cfg.ServerAddr, inFlight.Middleware(r), timeouts)
 
-	r := router.New(apiHandler)
-	srv := server.New(cfg.ServerAddr, r)
+	var redirect *http.Server
+	if cfg.TLSEnabled() {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("could not configure TLS: %w", err)
+		}
+		srv.TLSConfig = tlsConfig
+
+		if cfg.HTTPRedirectAddr != "" {
+			redirect = server.NewRedirect(cfg.HTTPRedirectAddr, cfg.ServerAddr, timeouts)
+		}
+	}
 
-	log.Printf("starting server on %s", cfg.ServerAddr)
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("could not start server: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 2)
+	go func() {
+		if srv.TLSConfig != nil {
+			log.Printf("starting HTTPS server on %s", cfg.ServerAddr)
+			serveErr <- srv.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("starting server on %s", cfg.ServerAddr)
+			serveErr <- srv.ListenAndServe()
+		}
+	}()
+	if redirect != nil {
+		go func() {
+			log.Printf("redirecting HTTP on %s to HTTPS", cfg.HTTPRedirectAddr)
+			serveErr <- redirect.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		if err != nil && err != http.ErrServerClosed {
+			return fmt.Errorf("could not start server: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		stop()
+	}
+
+	log.Printf("shutdown requested; failing readiness for %s before draining", cfg.ShutdownDelay)
+	healthHandler.SetShuttingDown()
+	time.Sleep(cfg.ShutdownDelay)
+
+	return shutdown(cfg.ShutdownTimeout, inFlight, srv, redirect)
+}
+
+// shutdown stops accepting connections and waits up to timeout for in-flight
+// requests to finish. Requests still running after that are logged and their
+// connections closed.
+func shutdown(timeout time.Duration, inFlight *server.InFlight, srv, redirect *http.Server) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if redirect != nil {
+		if err := redirect.Shutdown(ctx); err != nil {
+			log.Printf("could not shut down HTTP redirect server: %v", err)
+		}
+	}
+
+	log.Printf("draining in-flight requests for up to %s", timeout)
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("drain timeout exceeded: %v", err)
+		inFlight.LogRemaining()
+		return srv.Close()
+	}
+	return nil
+}
+
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.TLSSelfSigned {
+		log.Println("using a generated self-signed certificate; do not use in production")
+		return server.SelfSignedTLSConfig([]string{"localhost", "127.0.0.1", "::1"})
+	}
+	return server.NewTLSConfig(cfg.TLSCertFile, cfg.TLSKeyFile)
+}
+
+func newTokens(cfg *config.Config) (*auth.Tokens, error) {
+	tokenCfg := auth.TokenConfig{
+		HS256Secret: []byte(cfg.JWTSecret),
+		Issuer:      cfg.JWTIssuer,
+		TTL:         cfg.JWTTTL,
+	}
+	if cfg.JWTPrivateKeyFile != "" {
+		key, err := auth.ReadRSAPrivateKey(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read JWT private key: %w", err)
+		}
+		tokenCfg.RS256PrivateKey = key
+	}
+	if cfg.JWTPublicKeyFile != "" {
+		key, err := auth.ReadRSAPublicKey(cfg.JWTPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read JWT public key: %w", err)
+		}
+		tokenCfg.RS256PublicKey = key
 	}
+	return auth.NewTokens(tokenCfg)
 }
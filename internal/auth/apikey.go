@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// API key scopes, stored with each key.
+const (
+	ScopeRead         = "read"
+	ScopeStationWrite = "station:write"
+)
+
+// scopePermissions lists the permissions granted by each API key scope.
+var scopePermissions = map[string][]Permission{
+	ScopeRead:         {PermissionStationRead},
+	ScopeStationWrite: {PermissionStationWrite},
+}
+
+// apiKeyPrefixLen is the number of leading characters of a key that are kept
+// in clear text so keys can be told apart when listed.
+const apiKeyPrefixLen = 8
+
+// ValidateScopes checks that every scope is known.
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if _, ok := scopePermissions[scope]; !ok {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+// GenerateAPIKey returns a new random API key and its display prefix.
+// Only the hash of the key should be persisted.
+func GenerateAPIKey() (key, prefix string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key = base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:apiKeyPrefixLen], nil
+}
+
+// HashAPIKey returns the hex encoded SHA-256 hash of an API key. Keys carry
+// 256 bits of entropy, so a fast unsalted hash is sufficient for lookup.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyPrincipal returns the principal for a request authenticated with an API
+// key. It has no role, so it holds only the permissions its scopes grant.
+func APIKeyPrincipal(name string, scopes []string) *Principal {
+	p := &Principal{Username: "apikey:" + name}
+	for _, scope := range scopes {
+		p.Grants = append(p.Grants, scopePermissions[scope]...)
+	}
+	return p
+}
@@ -0,0 +1,27 @@
+package auth
+
+import "context"
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Username string
+	// Role is empty for API keys.
+	Role Role
+	// TagScopes restricts the stations the principal may modify. Empty means unrestricted.
+	TagScopes []string
+	// Grants are permissions given in addition to those of Role, e.g. by API key scopes.
+	Grants []Permission
+}
+
+type contextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored in ctx, if any.
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok && p != nil
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned when a bearer token is malformed, expired or has a bad signature.
+var ErrInvalidToken = errors.New("invalid token")
+
+// TokenConfig holds the keys used to sign and verify JWTs.
+// At least one of HS256Secret or the RS256 keys must be set. Tokens are signed
+// with RS256 when a private key is present and with HS256 otherwise.
+type TokenConfig struct {
+	HS256Secret     []byte
+	RS256PrivateKey *rsa.PrivateKey
+	RS256PublicKey  *rsa.PublicKey
+	Issuer          string
+	TTL             time.Duration
+}
+
+// Tokens issues and validates JWT bearer tokens.
+type Tokens struct {
+	cfg TokenConfig
+}
+
+type claims struct {
+	jwt.RegisteredClaims
+	Role      Role     `json:"role,omitempty"`
+	TagScopes []string `json:"tagScopes,omitempty"`
+}
+
+// NewTokens creates a new Tokens from the given configuration.
+func NewTokens(cfg TokenConfig) (*Tokens, error) {
+	if cfg.RS256PublicKey == nil && cfg.RS256PrivateKey != nil {
+		cfg.RS256PublicKey = &cfg.RS256PrivateKey.PublicKey
+	}
+	if len(cfg.HS256Secret) == 0 && cfg.RS256PublicKey == nil {
+		return nil, errors.New("no JWT key configured")
+	}
+	return &Tokens{cfg: cfg}, nil
+}
+
+// Issue signs a token for the principal and returns it with its expiry time.
+func (t *Tokens) Issue(p *Principal) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(t.cfg.TTL)
+	c := claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   p.Username,
+			Issuer:    t.cfg.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		Role:      p.Role,
+		TagScopes: p.TagScopes,
+	}
+
+	var (
+		signed string
+		err    error
+	)
+	switch {
+	case t.cfg.RS256PrivateKey != nil:
+		signed, err = jwt.NewWithClaims(jwt.SigningMethodRS256, c).SignedString(t.cfg.RS256PrivateKey)
+	case len(t.cfg.HS256Secret) > 0:
+		signed, err = jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(t.cfg.HS256Secret)
+	default:
+		return "", time.Time{}, errors.New("no JWT signing key configured")
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// Parse validates a token and returns the principal it was issued for.
+func (t *Tokens) Parse(token string) (*Principal, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	}
+	if t.cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(t.cfg.Issuer))
+	}
+
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, t.keyFunc, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if c.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	role := c.Role
+	if role == "" {
+		role = RoleViewer
+	}
+	return &Principal{Username: c.Subject, Role: role, TagScopes: c.TagScopes}, nil
+}
+
+// keyFunc selects the verification key matching the token's algorithm, so an
+// RS256 deployment never accepts HS256 tokens and vice versa.
+func (t *Tokens) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if len(t.cfg.HS256Secret) == 0 {
+			return nil, errors.New("HS256 tokens are not accepted")
+		}
+		return t.cfg.HS256Secret, nil
+	case *jwt.SigningMethodRSA:
+		if t.cfg.RS256PublicKey == nil {
+			return nil, errors.New("RS256 tokens are not accepted")
+		}
+		return t.cfg.RS256PublicKey, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
+
+// ReadRSAPrivateKey reads a PEM encoded RSA private key from a file.
+func ReadRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.ParseRSAPrivateKeyFromPEM(data)
+}
+
+// ReadRSAPublicKey reads a PEM encoded RSA public key from a file.
+func ReadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.ParseRSAPublicKeyFromPEM(data)
+}
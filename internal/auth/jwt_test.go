@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTokens(t *testing.T, cfg TokenConfig) *Tokens {
+	t.Helper()
+	tokens, err := NewTokens(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+// sign returns a token with the given claims signed by key.
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, c claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, c).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// validClaims returns claims that Parse accepts for a token issued by "odbus".
+func validClaims() claims {
+	return claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "alice",
+			Issuer:    "odbus",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Role: RoleEditor,
+	}
+}
+
+func TestTokensRoundTrip(t *testing.T) {
+	key := newRSAKey(t)
+	configs := map[string]TokenConfig{
+		"HS256": {HS256Secret: []byte("secret"), Issuer: "odbus", TTL: time.Hour},
+		"RS256": {RS256PrivateKey: key, Issuer: "odbus", TTL: time.Hour},
+	}
+	for name, cfg := range configs {
+		t.Run(name, func(t *testing.T) {
+			tokens := newTokens(t, cfg)
+			token, _, err := tokens.Issue(&Principal{Username: "alice", Role: RoleAdmin, TagScopes: []string{"north"}})
+			if err != nil {
+				t.Fatalf("Issue: %v", err)
+			}
+			p, err := tokens.Parse(token)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if p.Username != "alice" || p.Role != RoleAdmin || len(p.TagScopes) != 1 || p.TagScopes[0] != "north" {
+				t.Errorf("Parse() = %+v", p)
+			}
+		})
+	}
+}
+
+func TestTokensParseRejects(t *testing.T) {
+	key := newRSAKey(t)
+	publicPEM, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicPEM})
+
+	hs := newTokens(t, TokenConfig{HS256Secret: []byte("secret"), Issuer: "odbus"})
+	rs := newTokens(t, TokenConfig{RS256PublicKey: &key.PublicKey, Issuer: "odbus"})
+
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+	wrongIssuer := validClaims()
+	wrongIssuer.Issuer = "someone-else"
+	noSubject := validClaims()
+	noSubject.Subject = ""
+
+	tests := []struct {
+		name   string
+		tokens *Tokens
+		token  string
+	}{
+		// An RS256 deployment must not verify HS256 tokens with its public key as the secret.
+		{"HS256 token on RS256", rs, sign(t, jwt.SigningMethodHS256, publicPEM, validClaims())},
+		{"RS256 token on HS256", hs, sign(t, jwt.SigningMethodRS256, key, validClaims())},
+		{"other RSA key", rs, sign(t, jwt.SigningMethodRS256, newRSAKey(t), validClaims())},
+		{"other secret", hs, sign(t, jwt.SigningMethodHS256, []byte("other"), validClaims())},
+		{"HS512", hs, sign(t, jwt.SigningMethodHS512, []byte("secret"), validClaims())},
+		{"none", hs, sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims())},
+		{"expired", hs, sign(t, jwt.SigningMethodHS256, []byte("secret"), expired)},
+		{"no expiry", hs, sign(t, jwt.SigningMethodHS256, []byte("secret"), noExpiry)},
+		{"wrong issuer", hs, sign(t, jwt.SigningMethodHS256, []byte("secret"), wrongIssuer)},
+		{"missing subject", rs, sign(t, jwt.SigningMethodRS256, key, noSubject)},
+		{"malformed", hs, "not.a.token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := tt.tokens.Parse(tt.token)
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Parse() = %+v, %v; want ErrInvalidToken", p, err)
+			}
+		})
+	}
+}
+
+func TestTokensParseDefaultsRole(t *testing.T) {
+	c := validClaims()
+	c.Role = ""
+	tokens := newTokens(t, TokenConfig{HS256Secret: []byte("secret"), Issuer: "odbus"})
+	p, err := tokens.Parse(sign(t, jwt.SigningMethodHS256, []byte("secret"), c))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if p.Role != RoleViewer {
+		t.Errorf("role = %q, want %q", p.Role, RoleViewer)
+	}
+}
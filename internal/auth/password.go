@@ -0,0 +1,23 @@
+package auth
+
+import "golang.org/x/crypto/bcrypt"
+
+// HashPassword returns the bcrypt hash of a password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// UnknownUserHash is a bcrypt hash, at the default cost, of a random password
+// nobody knows. Checking a login against it when the user does not exist makes
+// the response take as long as for a real user, so timing does not reveal
+// which usernames exist.
+const UnknownUserHash = "$2a$10$85q4TbqsofJbIImzFbOc3eLNHrzomtbh6qmdwQasWLHj2hGt7x0Jq"
+
+// CheckPassword reports whether password matches the bcrypt hash.
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,77 @@
+package auth
+
+import "fmt"
+
+// Role is the access level of a principal.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+// Permission names an action guarded by role-based access control.
+type Permission string
+
+const (
+	// PermissionNone marks a route that anyone may call.
+	PermissionNone Permission = ""
+	// PermissionStationRead covers station history and deactivated stations;
+	// active stations are public.
+	PermissionStationRead  Permission = "station:read"
+	PermissionStationWrite Permission = "station:write"
+	PermissionStationPurge Permission = "station:purge"
+	PermissionBlockedSeed  Permission = "blockedSign:seed"
+)
+
+// rolePermissions lists the permissions granted to each role.
+var rolePermissions = map[Role][]Permission{
+	RoleViewer: {PermissionStationRead},
+	RoleEditor: {PermissionStationRead, PermissionStationWrite},
+	RoleAdmin:  {PermissionStationRead, PermissionStationWrite, PermissionStationPurge, PermissionBlockedSeed},
+}
+
+// ParseRole validates a role name.
+func ParseRole(s string) (Role, error) {
+	r := Role(s)
+	if _, ok := rolePermissions[r]; !ok {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return r, nil
+}
+
+// Can reports whether the principal has been granted the permission.
+func (p *Principal) Can(perm Permission) bool {
+	if perm == PermissionNone {
+		return true
+	}
+	for _, granted := range rolePermissions[p.Role] {
+		if granted == perm {
+			return true
+		}
+	}
+	for _, granted := range p.Grants {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// CanEditTags reports whether the principal may modify a station carrying the
+// given tags. Principals without tag scopes may edit any station; scoped
+// principals need at least one of their tags on the station.
+func (p *Principal) CanEditTags(tags []string) bool {
+	if len(p.TagScopes) == 0 {
+		return true
+	}
+	for _, scope := range p.TagScopes {
+		for _, tag := range tags {
+			if tag == scope {
+				return true
+			}
+		}
+	}
+	return false
+}
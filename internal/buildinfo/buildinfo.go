@@ -0,0 +1,47 @@
+package buildinfo
+
+import "runtime/debug"
+
+// Commit and BuildTime are set at link time, e.g.
+//
+//	go build -ldflags "-X go-https-server/internal/buildinfo.Commit=$(git rev-parse HEAD) -X go-https-server/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/server
+//
+// When they are not set, the VCS information embedded by the Go toolchain is used.
+var (
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info describes the running build.
+type Info struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// Get returns the build information of the running binary.
+func Get() Info {
+	info := Info{Commit: Commit, BuildTime: BuildTime}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.GoVersion = bi.GoVersion
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = s.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = s.Value
+			}
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
@@ -1,46 +1,431 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+const redacted = "REDACTED"
+
+// TLSEnabled reports whether the server should serve HTTPS.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSSelfSigned || c.TLSCertFile != ""
+}
+
+// Config is the effective configuration. Load fills it in layers: defaults,
+// then an optional YAML file, then environment variables (including those from
+// an optional .env file), then command-line flags.
 type Config struct {
-	DatabaseURL string
-	ServerAddr  string
+	// Database settings. DatabaseURL, when set, takes precedence over the
+	// individual connection settings.
+	DatabaseURL string `yaml:"database_url"`
+	DBHost      string `yaml:"db_host"`
+	DBPort      int    `yaml:"db_port"`
+	DBUser      string `yaml:"db_user"`
+	DBPassword  string `yaml:"db_password"`
+	DBName      string `yaml:"db_name"`
+	DBSSLMode   string `yaml:"db_sslmode"`
+
+	// Pool settings. Watch the go_sql_* pool gauges on /metrics (in use, idle,
+	// wait count and wait duration) when tuning them.
+	DBMaxOpenConns    int           `yaml:"db_max_open_conns"`
+	DBMaxIdleConns    int           `yaml:"db_max_idle_conns"`
+	DBConnMaxLifetime time.Duration `yaml:"db_conn_max_lifetime"`
+
+	// DBReadTimeout and DBWriteTimeout bound each store query and each store
+	// write respectively. Zero disables the bound.
+	DBReadTimeout  time.Duration `yaml:"db_read_timeout"`
+	DBWriteTimeout time.Duration `yaml:"db_write_timeout"`
+
+	ServerAddr         string        `yaml:"server_addr"`
+	ServerReadTimeout  time.Duration `yaml:"server_read_timeout"`
+	ServerWriteTimeout time.Duration `yaml:"server_write_timeout"`
+	ServerIdleTimeout  time.Duration `yaml:"server_idle_timeout"`
+	LogLevel           string        `yaml:"log_level"`
+
+	// CORS settings. CORSAllowedOrigins holds exact origins, wildcard subdomain
+	// patterns such as https://*.example.com, or "*". It is empty by default, so
+	// browsers can only call the API from the same origin.
+	CORSAllowedOrigins   []string      `yaml:"cors_allowed_origins"`
+	CORSAllowedMethods   []string      `yaml:"cors_allowed_methods"`
+	CORSAllowedHeaders   []string      `yaml:"cors_allowed_headers"`
+	CORSAllowCredentials bool          `yaml:"cors_allow_credentials"`
+	CORSMaxAge           time.Duration `yaml:"cors_max_age"`
+
+	// KMZMergeAll seeds blocked signs from every KML file in the KMZ archive,
+	// not only from its root document and the files that document links to.
+	KMZMergeAll bool `yaml:"kmz_merge_all"`
+
+	// JWT settings. At least one of JWTSecret or JWTPrivateKeyFile/JWTPublicKeyFile must be set.
+	JWTSecret         string        `yaml:"jwt_secret"`
+	JWTPrivateKeyFile string        `yaml:"jwt_private_key_file"`
+	JWTPublicKeyFile  string        `yaml:"jwt_public_key_file"`
+	JWTIssuer         string        `yaml:"jwt_issuer"`
+	JWTTTL            time.Duration `yaml:"jwt_ttl"`
+
+	// TLS settings. When TLSCertFile and TLSKeyFile are set the server speaks HTTPS
+	// and reloads the certificate when the files change. TLSSelfSigned generates a
+	// throwaway certificate instead, for development.
+	TLSCertFile      string `yaml:"tls_cert_file"`
+	TLSKeyFile       string `yaml:"tls_key_file"`
+	TLSSelfSigned    bool   `yaml:"tls_self_signed"`
+	HTTPRedirectAddr string `yaml:"http_redirect_addr"`
+
+	// ShutdownDelay is how long the server keeps serving with readiness failing
+	// before it stops accepting connections, so load balancers can react.
+	// ShutdownTimeout bounds how long in-flight requests may take to drain.
+	ShutdownDelay   time.Duration `yaml:"shutdown_delay"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	// Tracing settings. TracingExporter is one of none, stdout, file or otlp.
+	TracingExporter     string  `yaml:"tracing_exporter"`
+	TracingFile         string  `yaml:"tracing_file"`
+	TracingOTLPEndpoint string  `yaml:"tracing_otlp_endpoint"`
+	TracingSampleRatio  float64 `yaml:"tracing_sample_ratio"`
+
+	// PrintConfig is set by -print-config. The caller should print the
+	// effective configuration and exit instead of starting.
+	PrintConfig bool `yaml:"-"`
 }
 
-func Load() (*Config, error) {
-	if err := godotenv.Load(); err != nil {
+// defaults returns the configuration used when nothing overrides a setting.
+func defaults() *Config {
+	return &Config{
+		DBHost:    "localhost",
+		DBPort:    5432,
+		DBSSLMode: "disable",
+
+		DBMaxOpenConns:    25,
+		DBMaxIdleConns:    25,
+		DBConnMaxLifetime: 5 * time.Minute,
+
+		DBReadTimeout:  5 * time.Second,
+		DBWriteTimeout: 10 * time.Second,
+
+		ServerReadTimeout:  5 * time.Second,
+		ServerWriteTimeout: 10 * time.Second,
+		ServerIdleTimeout:  120 * time.Second,
+		LogLevel:           "info",
+
+		CORSAllowedMethods: []string{"POST", "OPTIONS"},
+		CORSAllowedHeaders: []string{"Content-Type", "Authorization", "token", "dt", "X-Request-ID"},
+		CORSMaxAge:         10 * time.Minute,
+
+		JWTTTL: 24 * time.Hour,
+
+		ShutdownDelay:   5 * time.Second,
+		ShutdownTimeout: 30 * time.Second,
+
+		TracingExporter:    "none",
+		TracingSampleRatio: 1,
+	}
+}
+
+// setting binds a Config field to its environment variable and flag.
+type setting struct {
+	flag   string
+	env    string
+	usage  string
+	secret bool
+	value  interface{} // pointer to the Config field
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"database-url", "DATABASE_URL", "PostgreSQL connection URL, overriding the other db settings", true, &c.DatabaseURL},
+		{"db-host", "DB_HOST", "database host", false, &c.DBHost},
+		{"db-port", "DB_PORT", "database port", false, &c.DBPort},
+		{"db-user", "POSTGRES_USER", "database user", false, &c.DBUser},
+		{"db-password", "POSTGRES_PASSWORD", "database password", true, &c.DBPassword},
+		{"db-name", "POSTGRES_DB", "database name", false, &c.DBName},
+		{"db-sslmode", "DB_SSLMODE", "database sslmode (disable, require, verify-ca or verify-full)", false, &c.DBSSLMode},
+		{"db-max-open-conns", "DB_MAX_OPEN_CONNS", "maximum open database connections", false, &c.DBMaxOpenConns},
+		{"db-max-idle-conns", "DB_MAX_IDLE_CONNS", "maximum idle database connections", false, &c.DBMaxIdleConns},
+		{"db-conn-max-lifetime", "DB_CONN_MAX_LIFETIME", "maximum lifetime of a database connection", false, &c.DBConnMaxLifetime},
+		{"db-read-timeout", "DB_READ_TIMEOUT", "timeout for store queries (0 disables)", false, &c.DBReadTimeout},
+		{"db-write-timeout", "DB_WRITE_TIMEOUT", "timeout for store writes (0 disables)", false, &c.DBWriteTimeout},
+
+		{"server-addr", "SERVER_ADDR", "address to listen on", false, &c.ServerAddr},
+		{"server-read-timeout", "SERVER_READ_TIMEOUT", "HTTP server read timeout", false, &c.ServerReadTimeout},
+		{"server-write-timeout", "SERVER_WRITE_TIMEOUT", "HTTP server write timeout", false, &c.ServerWriteTimeout},
+		{"server-idle-timeout", "SERVER_IDLE_TIMEOUT", "HTTP server idle timeout", false, &c.ServerIdleTimeout},
+		{"log-level", "LOG_LEVEL", "log level (debug, info, warn or error)", false, &c.LogLevel},
+
+		{"cors-allowed-origins", "CORS_ALLOWED_ORIGINS", "comma separated origins allowed by CORS, e.g. https://*.example.com", false, &c.CORSAllowedOrigins},
+		{"cors-allowed-methods", "CORS_ALLOWED_METHODS", "comma separated methods allowed by CORS", false, &c.CORSAllowedMethods},
+		{"cors-allowed-headers", "CORS_ALLOWED_HEADERS", "comma separated request headers allowed by CORS", false, &c.CORSAllowedHeaders},
+		{"cors-allow-credentials", "CORS_ALLOW_CREDENTIALS", "allow credentialed cross-origin requests", false, &c.CORSAllowCredentials},
+		{"cors-max-age", "CORS_MAX_AGE", "how long browsers may cache preflight results", false, &c.CORSMaxAge},
+
+		{"kmz-merge-all", "KMZ_MERGE_ALL", "seed blocked signs from every KML file in the KMZ archive", false, &c.KMZMergeAll},
+
+		{"jwt-secret", "JWT_SECRET", "HS256 signing secret", true, &c.JWTSecret},
+		{"jwt-private-key-file", "JWT_PRIVATE_KEY_FILE", "RS256 private key file", false, &c.JWTPrivateKeyFile},
+		{"jwt-public-key-file", "JWT_PUBLIC_KEY_FILE", "RS256 public key file", false, &c.JWTPublicKeyFile},
+		{"jwt-issuer", "JWT_ISSUER", "issuer of login tokens", false, &c.JWTIssuer},
+		{"jwt-ttl", "JWT_TTL", "lifetime of login tokens", false, &c.JWTTTL},
+
+		{"tls-cert-file", "TLS_CERT_FILE", "TLS certificate file", false, &c.TLSCertFile},
+		{"tls-key-file", "TLS_KEY_FILE", "TLS key file", false, &c.TLSKeyFile},
+		{"tls-self-signed", "TLS_SELF_SIGNED", "serve HTTPS with a generated self-signed certificate", false, &c.TLSSelfSigned},
+		{"http-redirect-addr", "HTTP_REDIRECT_ADDR", "address of an HTTP listener that redirects to HTTPS", false, &c.HTTPRedirectAddr},
+
+		{"shutdown-delay", "SHUTDOWN_DELAY", "time to keep serving with readiness failing before shutdown", false, &c.ShutdownDelay},
+		{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "time allowed for in-flight requests to drain", false, &c.ShutdownTimeout},
+
+		{"tracing-exporter", "TRACING_EXPORTER", "tracing exporter (none, stdout, file or otlp)", false, &c.TracingExporter},
+		{"tracing-file", "TRACING_FILE", "file the file exporter writes to", false, &c.TracingFile},
+		{"tracing-otlp-endpoint", "TRACING_OTLP_ENDPOINT", "OTLP/HTTP endpoint", false, &c.TracingOTLPEndpoint},
+		{"tracing-sample-ratio", "TRACING_SAMPLE_RATIO", "fraction of traces to sample", false, &c.TracingSampleRatio},
+	}
+}
+
+// Load builds the configuration from defaults, the YAML file named by
+// -config or CONFIG_FILE, the environment and the flags in args. A missing
+// .env file is not an error. Commands with flags of their own pass nil args.
+func Load(args []string) (*Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return nil, fmt.Errorf("error loading .env file: %w", err)
 	}
 
-	dbUser := os.Getenv("POSTGRES_USER")
-	if dbUser == "" {
-		return nil, fmt.Errorf("POSTGRES_USER is not set")
+	cfg := defaults()
+
+	// Flags are parsed first to find the config file, but applied last.
+	fset := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	configFile := fset.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file (defaults to $CONFIG_FILE)")
+	fset.BoolVar(&cfg.PrintConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+	flagValues := make(map[string]string)
+	for _, s := range cfg.settings() {
+		name := s.flag
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		record := func(v string) error {
+			flagValues[name] = v
+			return nil
+		}
+		// Bool settings may be given bare, e.g. -tls-self-signed.
+		if _, ok := s.value.(*bool); ok {
+			fset.BoolFunc(name, usage, record)
+		} else {
+			fset.Func(name, usage, record)
+		}
+	}
+	if err := fset.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, err
+		}
 	}
 
-	dbPassword := os.Getenv("POSTGRES_PASSWORD")
-	if dbPassword == "" {
-		return nil, fmt.Errorf("POSTGRES_PASSWORD is not set")
+	settings := cfg.settings()
+	for _, s := range settings {
+		// A variable that is set but empty clears the setting.
+		if v, ok := os.LookupEnv(s.env); ok {
+			if err := s.set(v); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if v, ok := flagValues[s.flag]; ok {
+			if err := s.set(v); err != nil {
+				return nil, fmt.Errorf("invalid -%s: %w", s.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.DatabaseURL == "" {
+		cfg.DatabaseURL = cfg.buildDatabaseURL()
 	}
+	return cfg, nil
+}
 
-	dbName := os.Getenv("POSTGRES_DB")
-	if dbName == "" {
-		return nil, fmt.Errorf("POSTGRES_DB is not set")
+// loadFile overlays the YAML file at path onto c.
+func (c *Config) loadFile(path string) error {
+	if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+		return fmt.Errorf("unsupported config file %s: only YAML is supported", path)
 	}
 
-	serverAddr := os.Getenv("SERVER_ADDR")
-	if serverAddr == "" {
-		return nil, fmt.Errorf("SERVER_ADDR is not set")
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open config file: %w", err)
 	}
+	defer f.Close()
 
-	databaseURL := fmt.Sprintf("postgres://%s:%s@localhost:5432/%s?sslmode=disable", dbUser, dbPassword, dbName)
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+	return nil
+}
 
-	return &Config{
-		DatabaseURL: databaseURL,
-		ServerAddr:  serverAddr,
-	}, nil
+// buildDatabaseURL assembles a connection URL from the individual db settings.
+func (c *Config) buildDatabaseURL() string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.DBUser, c.DBPassword),
+		Host:     fmt.Sprintf("%s:%d", c.DBHost, c.DBPort),
+		Path:     "/" + c.DBName,
+		RawQuery: url.Values{"sslmode": {c.DBSSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+func (c *Config) validate() error {
+	if c.DatabaseURL == "" {
+		if c.DBUser == "" {
+			return fmt.Errorf("POSTGRES_USER is not set")
+		}
+		if c.DBPassword == "" {
+			return fmt.Errorf("POSTGRES_PASSWORD is not set")
+		}
+		if c.DBName == "" {
+			return fmt.Errorf("POSTGRES_DB is not set")
+		}
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		return fmt.Errorf("invalid TRACING_SAMPLE_RATIO: must be between 0 and 1")
+	}
+	return nil
+}
+
+// ValidateServer checks the settings only the API server needs. Load does not
+// call it, so the other commands can run without them.
+func (c *Config) ValidateServer() error {
+	if c.ServerAddr == "" {
+		return fmt.Errorf("SERVER_ADDR is not set")
+	}
+
+	if c.JWTSecret == "" && c.JWTPrivateKeyFile == "" && c.JWTPublicKeyFile == "" {
+		return fmt.Errorf("one of JWT_SECRET, JWT_PRIVATE_KEY_FILE or JWT_PUBLIC_KEY_FILE must be set")
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.TLSSelfSigned && c.TLSCertFile != "" {
+		return fmt.Errorf("TLS_SELF_SIGNED cannot be combined with TLS_CERT_FILE")
+	}
+	if c.HTTPRedirectAddr != "" && !c.TLSEnabled() {
+		return fmt.Errorf("HTTP_REDIRECT_ADDR requires TLS: set TLS_CERT_FILE and TLS_KEY_FILE or TLS_SELF_SIGNED")
+	}
+
+	for _, origin := range c.CORSAllowedOrigins {
+		if origin == "*" {
+			if c.CORSAllowCredentials {
+				return fmt.Errorf("CORS_ALLOWED_ORIGINS cannot contain * when CORS_ALLOW_CREDENTIALS is set")
+			}
+			continue
+		}
+		if strings.Count(origin, "*") > 1 || (strings.Contains(origin, "*") && !strings.Contains(origin, "://*.")) {
+			return fmt.Errorf("invalid CORS origin %q: wildcards are only allowed as scheme://*.domain", origin)
+		}
+	}
+	// The CORS handler caps Access-Control-Max-Age at ten minutes.
+	if c.CORSMaxAge < 0 || c.CORSMaxAge > 10*time.Minute {
+		return fmt.Errorf("invalid CORS_MAX_AGE %s: must be between 0 and 10m", c.CORSMaxAge)
+	}
+	return nil
+}
+
+// WriteRedacted writes the configuration to w as YAML, with secrets replaced.
+func (c *Config) WriteRedacted(w io.Writer) error {
+	out := *c
+	for _, s := range out.settings() {
+		if p, ok := s.value.(*string); ok && s.secret && *p != "" {
+			*p = redacted
+		}
+	}
+	out.DatabaseURL = redactDSN(c.DatabaseURL)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&out); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// dsnPassword matches the password of a key=value connection string, quoted or not.
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactDSN hides the password in a connection URL or a key=value connection
+// string, whichever dsn is.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		if q := u.Query(); q.Has("password") {
+			q.Set("password", redacted)
+			u.RawQuery = q.Encode()
+		}
+		return u.Redacted()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
+
+// set parses v into the setting's Config field. An empty v resets the field
+// to its zero value.
+func (s setting) set(v string) error {
+	if v == "" {
+		reflect.ValueOf(s.value).Elem().SetZero()
+		return nil
+	}
+	switch p := s.value.(type) {
+	case *string:
+		*p = v
+	case *int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*p = b
+	case *float64:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		*p = f
+	case *time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*p = d
+	case *[]string:
+		var list []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*p = list
+	default:
+		return fmt.Errorf("unsupported setting type %T", p)
+	}
+	return nil
 }
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	path := writeConfig(t, `
+db_user: odbus
+db_password: secret
+db_name: odbus
+db_host: file-host
+db_port: 6543
+server_addr: ":8443"
+log_level: debug
+cors_allowed_origins: [https://app.example.com]
+`)
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DB_PORT", "7654")
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+	t.Setenv("SERVER_READ_TIMEOUT", "7s")
+
+	cfg, err := Load([]string{"-config", path, "-log-level", "error", "-server-read-timeout=9s"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"default", cfg.DBSSLMode, "disable"},
+		{"file over default", cfg.DBHost, "file-host"},
+		{"file only", cfg.ServerAddr, ":8443"},
+		{"env over file", cfg.DBPort, 7654},
+		{"flag over env and file", cfg.LogLevel, "error"},
+		{"flag over env", cfg.ServerReadTimeout, 9 * time.Second},
+		{"empty env clears file", cfg.CORSAllowedOrigins, []string(nil)},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.name, tt.got, tt.want)
+		}
+	}
+	if !strings.Contains(cfg.DatabaseURL, "@file-host:7654/odbus") {
+		t.Errorf("DatabaseURL = %q, want it built from the layered settings", cfg.DatabaseURL)
+	}
+}
+
+func TestLoadRejectsInvalidEnv(t *testing.T) {
+	path := writeConfig(t, "db_user: u\ndb_password: p\ndb_name: d\n")
+	t.Setenv("DB_PORT", "not a port")
+	if _, err := Load([]string{"-config", path}); err == nil || !strings.Contains(err.Error(), "DB_PORT") {
+		t.Errorf("Load error = %v, want one naming DB_PORT", err)
+	}
+}
@@ -7,21 +7,23 @@ import (
 	_ "github.com/lib/pq"
 )
 
-const (
-	maxOpenConns    = 25
-	maxIdleConns    = 25
-	connMaxLifetime = 5 * time.Minute
-)
+// Pool holds the connection pool settings. Watch the go_sql_* pool gauges on
+// /metrics (in use, idle, wait count and wait duration) when tuning them.
+type Pool struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
 
-func New(dsn string) (*sql.DB, error) {
+func New(dsn string, pool Pool) (*sql.DB, error) {
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(maxOpenConns)
-	db.SetMaxIdleConns(maxIdleConns)
-	db.SetConnMaxLifetime(connMaxLifetime)
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
 
 	return db, nil
 }
@@ -1,37 +1,300 @@
 package database
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
 
-// Migrate creates the necessary tables in the database if they don't exist.
+	"github.com/lib/pq"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the Postgres advisory lock held while migrating, so that
+// concurrent server starts apply migrations one at a time.
+const migrationLockID = 7_004_213_009
+
+// migrationFileName matches migration files such as 0002_add_index.up.sql.
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change with the SQL that applies and
+// reverts it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+var migrations = mustLoadMigrations(migrationFiles)
+
+// SchemaVersion is the schema version this build expects: the highest
+// numbered file in migrations/.
+var SchemaVersion = migrations[len(migrations)-1].Version
+
+// mustLoadMigrations reads the embedded migration files. Versions must start
+// at 1 and be contiguous, and every migration needs both an up and a down file.
+func mustLoadMigrations(fsys fs.FS) []Migration {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		panic(err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		m := migrationFileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			panic(fmt.Sprintf("invalid migration file name %q", e.Name()))
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(fsys, path.Join("migrations", e.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			panic(fmt.Sprintf("migration %d has files named %q and %q", version, mig.Name, m[2]))
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	for i, m := range list {
+		if m.Version != i+1 {
+			panic(fmt.Sprintf("migration %d is missing", i+1))
+		}
+		if m.Up == "" || m.Down == "" {
+			panic(fmt.Sprintf("migration %d needs both an up and a down file", m.Version))
+		}
+	}
+	if len(list) == 0 {
+		panic("no migrations found")
+	}
+	return list
+}
+
+// Migrate applies every pending migration.
 func Migrate(db *sql.DB) error {
-	if _, err := db.Exec("CREATE EXTENSION IF NOT EXISTS postgis"); err != nil {
-		return err
+	_, err := MigrateUp(context.Background(), db)
+	return err
+}
+
+// MigrateUp applies every pending migration in order, each in its own
+// transaction, and returns the ones it applied.
+func MigrateUp(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	var done []Migration
+	err := withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("could not apply migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown reverts the n most recently applied migrations, newest first,
+// and returns the ones it reverted.
+func MigrateDown(ctx context.Context, db *sql.DB, n int) ([]Migration, error) {
+	var done []Migration
+	err := withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && len(done) < n; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("could not revert migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrationStatuses lists every known migration with the time it was applied.
+// It only reads, without taking the migration lock, so it does not wait for a
+// running migration. A database without a schema_migrations table has nothing
+// applied.
+func MigrationStatuses(ctx context.Context, db *sql.DB) ([]MigrationStatus, error) {
+	applied, err := appliedVersions(ctx, db)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pqCodeUndefinedTable {
+		applied, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	createBlockedSignsTable := `
-	CREATE TABLE IF NOT EXISTS blockedSigns (
-		id SERIAL PRIMARY KEY,
-		location GEOGRAPHY(Point, 4326) NOT NULL
-	);`
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		status := MigrationStatus{Migration: m}
+		if t, ok := applied[m.Version]; ok {
+			status.AppliedAt = &t
+		}
+		statuses = append(statuses, status)
+		delete(applied, m.Version)
+	}
+	// Versions left over were applied by a newer build.
+	for version, t := range applied {
+		statuses = append(statuses, MigrationStatus{Migration: Migration{Version: version, Name: "unknown"}, AppliedAt: &t})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// pqCodeUndefinedTable is the Postgres error code for a missing table.
+const pqCodeUndefinedTable = "42P01"
+
+// querier is satisfied by *sql.DB and *sql.Conn.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
 
-	if _, err := db.Exec(createBlockedSignsTable); err != nil {
+// ForceVersion records migrations 1 to version as applied and the rest as
+// not applied, without running any SQL. It is meant for repairing the
+// schema_migrations table after a migration was fixed up by hand.
+func ForceVersion(ctx context.Context, db *sql.DB, version int) error {
+	if version < 0 || version > SchemaVersion {
+		return fmt.Errorf("version must be between 0 and %d", SchemaVersion)
+	}
+	return withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		return inTx(ctx, conn, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version > $1", version); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `
+			INSERT INTO schema_migrations (version)
+			SELECT generate_series(1, $1::int)
+			ON CONFLICT DO NOTHING`, version)
+			return err
+		})
+	})
+}
+
+// withMigrationLock runs fn on a single connection holding the migration
+// advisory lock, after making sure the schema_migrations table exists.
+func withMigrationLock(ctx context.Context, db *sql.DB, fn func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
 		return err
 	}
+	defer conn.Close()
 
-	createStationsTable := `
-	CREATE TABLE IF NOT EXISTS stations (
-		id SERIAL PRIMARY KEY,
-		name VARCHAR(255) NOT NULL,
-		location GEOGRAPHY(Point, 4326) NOT NULL,
-		"createdBy" VARCHAR(255) NOT NULL,
-		"createdAt" TIMESTAMPTZ DEFAULT NOW(),
-		"updatedAt" TIMESTAMPTZ,
-		"isActive" BOOLEAN DEFAULT TRUE,
-		tags TEXT[]
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("could not acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	createSchemaMigrationsTable := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		"appliedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
 	);`
-	if _, err := db.Exec(createStationsTable); err != nil {
+	if _, err := conn.ExecContext(ctx, createSchemaMigrationsTable); err != nil {
 		return err
 	}
 
-	return nil
+	return fn(conn)
+}
+
+// appliedVersions returns the applied migration versions with their apply times.
+func appliedVersions(ctx context.Context, q querier) (map[int]time.Time, error) {
+	rows, err := q.QueryContext(ctx, `SELECT version, "appliedAt" FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// CurrentVersion returns the highest schema version applied to the database, or 0 if none.
+func CurrentVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// PostGISVersion returns the installed PostGIS version, or an error if the extension is missing.
+func PostGISVersion(ctx context.Context, db *sql.DB) (string, error) {
+	var version string
+	err := db.QueryRowContext(ctx, "SELECT extversion FROM pg_extension WHERE extname = 'postgis'").Scan(&version)
+	return version, err
 }
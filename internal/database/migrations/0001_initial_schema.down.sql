@@ -0,0 +1,6 @@
+-- The postgis extension is left installed, as other schemas may use it.
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS station_history;
+DROP TABLE IF EXISTS stations;
+DROP TABLE IF EXISTS blockedSigns;
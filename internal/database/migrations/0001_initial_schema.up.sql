@@ -0,0 +1,52 @@
+-- The initial schema. Every statement is idempotent so that databases created
+-- before versioned migrations existed can adopt it.
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+CREATE TABLE IF NOT EXISTS blockedSigns (
+	id SERIAL PRIMARY KEY,
+	location GEOGRAPHY(Point, 4326) NOT NULL
+);
+CREATE INDEX IF NOT EXISTS blockedSigns_location_idx ON blockedSigns USING GIST (location);
+
+CREATE TABLE IF NOT EXISTS stations (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	location GEOGRAPHY(Point, 4326) NOT NULL,
+	"createdBy" VARCHAR(255) NOT NULL,
+	"createdAt" TIMESTAMPTZ DEFAULT NOW(),
+	"updatedAt" TIMESTAMPTZ,
+	"isActive" BOOLEAN DEFAULT TRUE,
+	tags TEXT[]
+);
+CREATE INDEX IF NOT EXISTS stations_location_idx ON stations USING GIST (location);
+
+CREATE TABLE IF NOT EXISTS station_history (
+	id SERIAL PRIMARY KEY,
+	"stationId" INTEGER NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	actor VARCHAR(255) NOT NULL,
+	before JSONB,
+	after JSONB,
+	"changedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS station_history_station_idx ON station_history ("stationId", "changedAt");
+
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	username VARCHAR(255) NOT NULL UNIQUE,
+	"passwordHash" VARCHAR(255) NOT NULL,
+	"createdAt" TIMESTAMPTZ DEFAULT NOW()
+);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(32) NOT NULL DEFAULT 'viewer';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS "tagScopes" TEXT[];
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	prefix VARCHAR(16) NOT NULL,
+	"keyHash" CHAR(64) NOT NULL UNIQUE,
+	scopes TEXT[] NOT NULL,
+	"createdAt" TIMESTAMPTZ DEFAULT NOW(),
+	"lastUsedAt" TIMESTAMPTZ,
+	"revokedAt" TIMESTAMPTZ
+);
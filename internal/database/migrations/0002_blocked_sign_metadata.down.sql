@@ -0,0 +1,6 @@
+ALTER TABLE blockedSigns
+	DROP COLUMN name,
+	DROP COLUMN description,
+	DROP COLUMN "styleUrl",
+	DROP COLUMN "folderPath",
+	DROP COLUMN properties;
@@ -0,0 +1,6 @@
+ALTER TABLE blockedSigns
+	ADD COLUMN name TEXT,
+	ADD COLUMN description TEXT,
+	ADD COLUMN "styleUrl" TEXT,
+	ADD COLUMN "folderPath" TEXT[],
+	ADD COLUMN properties JSONB NOT NULL DEFAULT '{}';
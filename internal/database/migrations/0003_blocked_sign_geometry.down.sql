@@ -0,0 +1,3 @@
+-- Only points fit the old column type, so other geometries are removed.
+DELETE FROM blockedSigns WHERE GeometryType(location) <> 'POINT';
+ALTER TABLE blockedSigns ALTER COLUMN location TYPE GEOGRAPHY(Point, 4326);
@@ -0,0 +1,2 @@
+-- Blocked signs can be lines and polygons as well as points.
+ALTER TABLE blockedSigns ALTER COLUMN location TYPE GEOGRAPHY(Geometry, 4326);
@@ -0,0 +1 @@
+ALTER TABLE blockedSigns DROP COLUMN "sourceFile";
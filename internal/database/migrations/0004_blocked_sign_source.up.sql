@@ -0,0 +1,2 @@
+-- Records the KML file inside, or linked from, the KMZ archive that each blocked sign came from.
+ALTER TABLE blockedSigns ADD COLUMN "sourceFile" TEXT;
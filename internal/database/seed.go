@@ -1,54 +1,167 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 
+	"github.com/lib/pq"
 	"go-https-server/internal/kml"
+	"go-https-server/internal/metrics"
 )
 
 // SeedBlockedSigns populates the blockedSigns table from a KMZ file if the table is empty.
-func SeedBlockedSigns(db *sql.DB, kmzPath string) error {
+func SeedBlockedSigns(ctx context.Context, db *sql.DB, kmzPath string, opts kml.KMZOptions) error {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM blockedSigns").Scan(&count)
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM blockedSigns").Scan(&count)
 	if err != nil {
 		return fmt.Errorf("could not query blockedSigns count: %w", err)
 	}
 
 	if count > 0 {
 		log.Println("blockedSigns table already seeded")
+		metrics.BlockedSignSeedRuns.WithLabelValues("skipped").Inc()
 		return nil
 	}
 
-	log.Printf("seeding data from %s", kmzPath)
+	_, err = seedBlockedSigns(ctx, db, kmzPath, opts, false)
+	return err
+}
 
-	latLongs, err := kml.ParseKMZ(kmzPath)
+// ReseedBlockedSigns replaces the contents of the blockedSigns table with the
+// records of a KMZ file. The report counts the records inserted and lists the
+// Placemarks that were rejected. The transaction is rolled back if ctx is
+// cancelled before it commits.
+func ReseedBlockedSigns(ctx context.Context, db *sql.DB, kmzPath string, opts kml.KMZOptions) (kml.Report, error) {
+	return seedBlockedSigns(ctx, db, kmzPath, opts, true)
+}
+
+// DryRunBlockedSigns parses a KMZ file as seeding would, without touching the
+// database, and reports what would be inserted and rejected.
+func DryRunBlockedSigns(kmzPath string, opts kml.KMZOptions) (kml.Report, error) {
+	log.Printf("checking %s", kmzPath)
+	report, err := kml.DecodeKMZ(kmzPath, opts, func(kml.Feature) error { return nil })
 	if err != nil {
-		return fmt.Errorf("could not parse KMZ file: %w", err)
+		return report, fmt.Errorf("could not parse KMZ file: %w", err)
 	}
+	logReport(report)
+	return report, nil
+}
 
-	txn, err := db.Begin()
+func seedBlockedSigns(ctx context.Context, db *sql.DB, kmzPath string, opts kml.KMZOptions, replace bool) (kml.Report, error) {
+	report, err := insertBlockedSigns(ctx, db, kmzPath, opts, replace)
 	if err != nil {
-		return fmt.Errorf("could not begin transaction: %w", err)
+		metrics.BlockedSignSeedRuns.WithLabelValues("error").Inc()
+		return report, err
 	}
-	defer txn.Rollback()
+	metrics.BlockedSignSeedRuns.WithLabelValues("success").Inc()
+	metrics.BlockedSignsSeeded.Add(float64(report.Features))
+	return report, nil
+}
+
+// logReport logs each rejected Placemark and unresolved NetworkLink, what
+// each KML file contributed and a summary of the report.
+func logReport(report kml.Report) {
+	for _, d := range report.Rejected {
+		log.Printf("rejected placemark %d on line %d of %s (folder %q, name %q): %s",
+			d.Index, d.Line, d.Source, strings.Join(d.FolderPath, "/"), d.Name, d.Reason)
+	}
+	for _, l := range report.UnresolvedLinks {
+		log.Printf("unresolved network link %q in %s: %s", l.Href, l.Source, l.Reason)
+	}
+	for _, s := range report.Sources {
+		log.Printf("%s: %d placemarks, %d accepted, %d rejected", s.File, s.Placemarks, s.Features, s.Rejected)
+	}
+	log.Printf("parsed %d placemarks: %d accepted, %d rejected", report.Placemarks, report.Features, len(report.Rejected))
+}
+
+// seedBatchSize is the number of features sent to Postgres per COPY.
+const seedBatchSize = 5000
+
+// insertBlockedSigns streams the features of the KMZ file into the
+// blockedSigns table through COPY, one batch at a time, within a single
+// transaction.
+func insertBlockedSigns(ctx context.Context, db *sql.DB, kmzPath string, opts kml.KMZOptions, replace bool) (kml.Report, error) {
+	log.Printf("seeding data from %s", kmzPath)
 
-	stmt, err := txn.Prepare("INSERT INTO blockedSigns (location) VALUES (ST_SetSRID(ST_MakePoint($1, $2), 4326))")
+	txn, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("could not prepare statement: %w", err)
+		return kml.Report{}, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer txn.Rollback()
+
+	if replace {
+		if _, err := txn.ExecContext(ctx, "DELETE FROM blockedSigns"); err != nil {
+			return kml.Report{}, fmt.Errorf("could not clear blockedSigns table: %w", err)
+		}
 	}
-	defer stmt.Close()
 
 	log.Println("inserting records into blockedSigns table...")
 
-	for _, ll := range latLongs {
-		if _, err := stmt.Exec(ll.Longitude, ll.Latitude); err != nil {
-			return fmt.Errorf("could not execute statement: %w", err)
+	count := 0
+	batch := make([]kml.Feature, 0, seedBatchSize)
+	flush := func() error {
+		if err := copyBlockedSigns(ctx, txn, batch); err != nil {
+			return err
 		}
+		count += len(batch)
+		batch = batch[:0]
+		log.Printf("inserted %d records into blockedSigns table", count)
+		return nil
+	}
+
+	report, err := kml.DecodeKMZ(kmzPath, opts, func(f kml.Feature) error {
+		batch = append(batch, f)
+		if len(batch) < seedBatchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return report, fmt.Errorf("could not seed from KMZ file: %w", err)
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return report, fmt.Errorf("could not seed from KMZ file: %w", err)
+		}
+	}
+
+	if err := txn.Commit(); err != nil {
+		return report, fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	logReport(report)
+	log.Printf("seeded %d records into blockedSigns table", count)
+
+	return report, nil
+}
+
+// copyBlockedSigns inserts features with a single COPY statement.
+func copyBlockedSigns(ctx context.Context, txn *sql.Tx, features []kml.Feature) error {
+	// COPY quotes identifiers, so the unquoted table name must be given in lower case.
+	stmt, err := txn.PrepareContext(ctx, pq.CopyIn("blockedsigns", "location", "name", "description", "styleUrl", "folderPath", "properties", "sourceFile"))
+	if err != nil {
+		return fmt.Errorf("could not prepare COPY: %w", err)
 	}
+	defer stmt.Close()
 
-	log.Printf("seeded %d records into blockedSigns table", len(latLongs))
+	for _, f := range features {
+		properties := []byte("{}")
+		if f.ExtendedData != nil {
+			if properties, err = json.Marshal(f.ExtendedData); err != nil {
+				return fmt.Errorf("could not encode properties of %q: %w", f.Name, err)
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, f.Geometry.EWKT(), f.Name, f.Description, f.StyleURL, pq.StringArray(f.FolderPath), string(properties), f.Source); err != nil {
+			return fmt.Errorf("could not copy %q: %w", f.Name, err)
+		}
+	}
 
-	return txn.Commit()
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("could not finish COPY: %w", err)
+	}
+	return nil
 }
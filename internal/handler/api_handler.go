@@ -2,8 +2,12 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"time"
 
+	"go-https-server/internal/auth"
 	"go-https-server/internal/models"
 	"go-https-server/internal/store"
 )
@@ -28,9 +32,45 @@ type StationCreateReq struct {
 
 // GetBlockedSigns handles POST /api/blockedSign/qry
 func (h *ApiHandler) GetBlockedSigns(w http.ResponseWriter, r *http.Request) {
-	signs, err := h.store.GetBlockedSigns()
+	signs, err := h.store.GetBlockedSigns(r.Context())
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		respondWithStoreError(w, r, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, signs)
+}
+
+// BlockedSignByBboxQryReq is the request DTO for querying blocked signs inside a bounding box.
+type BlockedSignByBboxQryReq struct {
+	MinLatitude  float64 `json:"minLatitude"`
+	MinLongitude float64 `json:"minLongitude"`
+	MaxLatitude  float64 `json:"maxLatitude"`
+	MaxLongitude float64 `json:"maxLongitude"`
+	Limit        int     `json:"limit"`
+}
+
+// GetBlockedSignsByBbox handles POST /api/blockedSign/qryByBbox
+func (h *ApiHandler) GetBlockedSignsByBbox(w http.ResponseWriter, r *http.Request) {
+	var req BlockedSignByBboxQryReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	bbox := models.Bbox{
+		MinLatitude:  req.MinLatitude,
+		MinLongitude: req.MinLongitude,
+		MaxLatitude:  req.MaxLatitude,
+		MaxLongitude: req.MaxLongitude,
+	}
+	if msg := validateBbox(bbox, req.Limit); msg != "" {
+		respondWithError(w, http.StatusUnprocessableEntity, msg)
+		return
+	}
+
+	signs, err := h.store.GetBlockedSignsByBbox(r.Context(), bbox, req.Limit)
+	if err != nil {
+		respondWithStoreError(w, r, err)
 		return
 	}
 	respondWithJSON(w, http.StatusOK, signs)
@@ -51,24 +91,159 @@ func (h *ApiHandler) CreateStation(w http.ResponseWriter, r *http.Request) {
 		Tags:      req.Tags,
 	}
 
-	if err := h.store.CreateStation(st); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+	if err := h.store.CreateStation(r.Context(), st); err != nil {
+		respondWithStoreError(w, r, err)
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, st)
 }
 
+// StationQryReq is the request DTO for querying all stations. The body is optional.
+type StationQryReq struct {
+	IncludeInactive bool `json:"includeInactive"`
+}
+
 // GetStations handles POST /api/station/qry
 func (h *ApiHandler) GetStations(w http.ResponseWriter, r *http.Request) {
-	points, err := h.store.GetStations()
+	var req StationQryReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Bad Request")
+		return
+	}
+	if req.IncludeInactive && !requirePermission(w, r, auth.PermissionStationRead) {
+		return
+	}
+
+	points, err := h.store.GetStations(r.Context(), req.IncludeInactive)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		respondWithStoreError(w, r, err)
 		return
 	}
 	respondWithJSON(w, http.StatusOK, points)
 }
 
+// StationByLocationQryReq is the request DTO for querying stations near a point.
+type StationByLocationQryReq struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Radius    float64 `json:"radius"`
+}
+
+// GetStationsByLocation handles POST /api/station/qryByLocation
+func (h *ApiHandler) GetStationsByLocation(w http.ResponseWriter, r *http.Request) {
+	var req StationByLocationQryReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	if req.Latitude < -90 || req.Latitude > 90 || req.Longitude < -180 || req.Longitude > 180 {
+		respondWithError(w, http.StatusUnprocessableEntity, "Invalid coordinates")
+		return
+	}
+	if req.Radius <= 0 {
+		respondWithError(w, http.StatusUnprocessableEntity, "Radius must be positive")
+		return
+	}
+
+	stations, err := h.store.GetStationsByLocation(r.Context(), req.Latitude, req.Longitude, req.Radius)
+	if err != nil {
+		respondWithStoreError(w, r, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, stations)
+}
+
+// StationByBboxQryReq is the request DTO for querying stations inside a bounding box.
+type StationByBboxQryReq struct {
+	MinLatitude  float64 `json:"minLatitude"`
+	MinLongitude float64 `json:"minLongitude"`
+	MaxLatitude  float64 `json:"maxLatitude"`
+	MaxLongitude float64 `json:"maxLongitude"`
+	Limit        int     `json:"limit"`
+}
+
+// GetStationsByBbox handles POST /api/station/qryByBbox
+func (h *ApiHandler) GetStationsByBbox(w http.ResponseWriter, r *http.Request) {
+	var req StationByBboxQryReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	bbox := models.Bbox{
+		MinLatitude:  req.MinLatitude,
+		MinLongitude: req.MinLongitude,
+		MaxLatitude:  req.MaxLatitude,
+		MaxLongitude: req.MaxLongitude,
+	}
+	if msg := validateBbox(bbox, req.Limit); msg != "" {
+		respondWithError(w, http.StatusUnprocessableEntity, msg)
+		return
+	}
+
+	stations, err := h.store.GetStationsByBbox(r.Context(), bbox, req.Limit)
+	if err != nil {
+		respondWithStoreError(w, r, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, stations)
+}
+
+// StationLstFilter is the filter DTO of a ListReq sent to /api/station/lst.
+type StationLstFilter struct {
+	NameContains string     `json:"nameContains"`
+	TagsAny      []string   `json:"tagsAny"`
+	TagsAll      []string   `json:"tagsAll"`
+	IsActive     *bool      `json:"isActive"`
+	CreatedBy    string     `json:"createdBy"`
+	CreatedFrom  *time.Time `json:"createdFrom"`
+	CreatedTo    *time.Time `json:"createdTo"`
+}
+
+// ListStations handles POST /api/station/lst
+func (h *ApiHandler) ListStations(w http.ResponseWriter, r *http.Request) {
+	var req ListReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	var filter StationLstFilter
+	if len(req.Filter) > 0 {
+		if err := json.Unmarshal(req.Filter, &filter); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Bad Request")
+			return
+		}
+	}
+	// Callers without read permission only see active stations.
+	if !canRead(r) {
+		if filter.IsActive != nil && !*filter.IsActive {
+			requirePermission(w, r, auth.PermissionStationRead)
+			return
+		}
+		active := true
+		filter.IsActive = &active
+	}
+
+	stations, total, err := h.store.ListStations(r.Context(), store.StationFilter{
+		NameContains: filter.NameContains,
+		TagsAny:      filter.TagsAny,
+		TagsAll:      filter.TagsAll,
+		IsActive:     filter.IsActive,
+		CreatedBy:    filter.CreatedBy,
+		CreatedFrom:  filter.CreatedFrom,
+		CreatedTo:    filter.CreatedTo,
+	}, req.listOptions())
+	if err != nil {
+		respondWithStoreError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, ListRes{Rows: stations, Total: total})
+}
+
 // StationRequestByID is the request DTO for getting a station by ID.
 type StationRequestByID struct {
 	ID int `json:"id"`
@@ -82,12 +257,13 @@ func (h *ApiHandler) GetStationByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	station, err := h.store.GetStationByID(req.ID)
+	station, err := h.store.GetStationByID(r.Context(), req.ID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		respondWithStoreError(w, r, err)
 		return
 	}
-	if station == nil {
+	// Inactive stations are hidden from callers without read access, as in the list endpoints.
+	if station == nil || (!station.IsActive && !canRead(r)) {
 		respondWithError(w, http.StatusNotFound, "Station not found")
 		return
 	}
@@ -96,10 +272,14 @@ func (h *ApiHandler) GetStationByID(w http.ResponseWriter, r *http.Request) {
 }
 
 // StationUpdateReq is the request DTO for updating a station.
+// Omitted fields are left unchanged.
 type StationUpdateReq struct {
-	ID   int      `json:"id"`
-	Name string   `json:"name"`
-	Tags []string `json:"tags"`
+	ID        int       `json:"id"`
+	Name      *string   `json:"name"`
+	Latitude  *float64  `json:"latitude"`
+	Longitude *float64  `json:"longitude"`
+	IsActive  *bool     `json:"isActive"`
+	Tags      *[]string `json:"tags"`
 }
 
 // UpdateStation handles POST /api/station/update
@@ -110,14 +290,21 @@ func (h *ApiHandler) UpdateStation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	st := &models.Station{
-		ID:   req.ID,
-		Name: req.Name,
-		Tags: req.Tags,
+	if (req.Latitude != nil && (*req.Latitude < -90 || *req.Latitude > 90)) ||
+		(req.Longitude != nil && (*req.Longitude < -180 || *req.Longitude > 180)) {
+		respondWithError(w, http.StatusUnprocessableEntity, "Invalid coordinates")
+		return
 	}
 
-	if err := h.store.UpdateStation(st); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+	st, err := h.store.UpdateStation(r.Context(), req.ID, store.StationPatch{
+		Name:      req.Name,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		IsActive:  req.IsActive,
+		Tags:      req.Tags,
+	})
+	if err != nil {
+		respondWithStoreError(w, r, err)
 		return
 	}
 
@@ -137,10 +324,73 @@ func (h *ApiHandler) DeleteStation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.store.DeleteStation(req.ID); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+	if err := h.store.DeleteStation(r.Context(), req.ID); err != nil {
+		respondWithStoreError(w, r, err)
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Station deleted successfully"})
 }
+
+// StationRestoreReq is the request DTO for restoring a deleted station.
+type StationRestoreReq struct {
+	ID int `json:"id"`
+}
+
+// RestoreStation handles POST /api/station/restore
+func (h *ApiHandler) RestoreStation(w http.ResponseWriter, r *http.Request) {
+	var req StationRestoreReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	if err := h.store.RestoreStation(r.Context(), req.ID); err != nil {
+		respondWithStoreError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Station restored successfully"})
+}
+
+// StationPurgeReq is the request DTO for permanently removing a station.
+type StationPurgeReq struct {
+	ID int `json:"id"`
+}
+
+// PurgeStation handles POST /api/station/purge
+func (h *ApiHandler) PurgeStation(w http.ResponseWriter, r *http.Request) {
+	var req StationPurgeReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	if err := h.store.PurgeStation(r.Context(), req.ID); err != nil {
+		respondWithStoreError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Station purged successfully"})
+}
+
+// StationHistoryQryReq is the request DTO for querying the change history of a station.
+type StationHistoryQryReq struct {
+	StationID int `json:"stationId"`
+}
+
+// GetStationHistory handles POST /api/station/qryOfHistory
+func (h *ApiHandler) GetStationHistory(w http.ResponseWriter, r *http.Request) {
+	var req StationHistoryQryReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	history, err := h.store.GetStationHistory(r.Context(), req.StationID)
+	if err != nil {
+		respondWithStoreError(w, r, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, history)
+}
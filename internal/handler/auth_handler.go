@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go-https-server/internal/auth"
+	"go-https-server/internal/store"
+)
+
+// AuthHandler handles authentication requests.
+type AuthHandler struct {
+	store  *store.Store
+	tokens *auth.Tokens
+}
+
+// NewAuthHandler creates a new AuthHandler.
+func NewAuthHandler(s *store.Store, tokens *auth.Tokens) *AuthHandler {
+	return &AuthHandler{store: s, tokens: tokens}
+}
+
+// AuthLoginReq is the request DTO for logging in.
+type AuthLoginReq struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// AuthLoginRes is the response DTO of a successful login.
+type AuthLoginRes struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Login handles POST /api/auth/login
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req AuthLoginReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	user, err := h.store.GetUserByUsername(r.Context(), req.Username)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		respondWithStoreError(w, r, err)
+		return
+	}
+	if user == nil {
+		auth.CheckPassword(auth.UnknownUserHash, req.Password)
+		respondWithError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+	if !auth.CheckPassword(user.PasswordHash, req.Password) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+
+	principal := &auth.Principal{
+		Username:  user.Username,
+		Role:      auth.Role(user.Role),
+		TagScopes: user.TagScopes,
+	}
+	token, expiresAt, err := h.tokens.Issue(principal)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "could not issue token", "user", user.Username, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, AuthLoginRes{Token: token, ExpiresAt: expiresAt})
+}
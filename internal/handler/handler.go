@@ -2,9 +2,45 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
+
+	"go-https-server/internal/auth"
+	"go-https-server/internal/models"
+	"go-https-server/internal/store"
 )
 
+// ListReq is the request DTO shared by the lst endpoints of the Generic List component.
+// Filter is decoded into an entity-specific filter DTO by each handler.
+type ListReq struct {
+	Filter   json.RawMessage `json:"filter"`
+	Sort     []ListSortReq   `json:"sort"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"pageSize"`
+}
+
+// ListSortReq is a single sort key of a ListReq.
+type ListSortReq struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+}
+
+// ListRes is the response DTO of the lst endpoints.
+type ListRes struct {
+	Rows  interface{} `json:"rows"`
+	Total int         `json:"total"`
+}
+
+// listOptions converts the sorting and paging part of a ListReq for the store.
+func (req *ListReq) listOptions() store.ListOptions {
+	opts := store.ListOptions{Page: req.Page, PageSize: req.PageSize}
+	for _, s := range req.Sort {
+		opts.Sort = append(opts.Sort, store.SortField{Field: s.Field, Desc: s.Desc})
+	}
+	return opts
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, err := json.Marshal(map[string]interface{}{
 		"error":   false,
@@ -22,12 +58,39 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Write(response)
 }
 
+// Error codes returned in the "code" field of error responses, mirrored by the client's ErrorEnum.
+const (
+	CodeUnknown      = 1
+	CodeBadRequest   = 2
+	CodeNotFound     = 3
+	CodeConflict     = 4
+	CodeInvalidInput = 5
+	CodeUnauthorized = 6
+	CodeForbidden    = 7
+	CodeTimeout      = 8
+)
+
+// errorCodes maps HTTP statuses to the error code reported to the client.
+var errorCodes = map[int]int{
+	http.StatusBadRequest:          CodeBadRequest,
+	http.StatusNotFound:            CodeNotFound,
+	http.StatusConflict:            CodeConflict,
+	http.StatusUnprocessableEntity: CodeInvalidInput,
+	http.StatusUnauthorized:        CodeUnauthorized,
+	http.StatusForbidden:           CodeForbidden,
+	http.StatusGatewayTimeout:      CodeTimeout,
+}
+
 func respondWithError(w http.ResponseWriter, code int, message string) {
-	// Using a generic error code 1 for client-side mapping to ErrorEnum.unknown
+	errorCode, ok := errorCodes[code]
+	if !ok {
+		errorCode = CodeUnknown
+	}
+
 	response, err := json.Marshal(map[string]interface{}{
 		"error":   true,
 		"message": message,
-		"code":    1,
+		"code":    errorCode,
 	})
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -38,3 +101,69 @@ func respondWithError(w http.ResponseWriter, code int, message string) {
 	w.WriteHeader(code)
 	w.Write(response)
 }
+
+// validateBbox returns a client-facing message describing why the bounding box
+// or limit is invalid, or an empty string if they are acceptable.
+func validateBbox(bbox models.Bbox, limit int) string {
+	if bbox.MinLatitude < -90 || bbox.MaxLatitude > 90 || bbox.MinLongitude < -180 || bbox.MaxLongitude > 180 {
+		return "Invalid coordinates"
+	}
+	if bbox.MinLatitude > bbox.MaxLatitude || bbox.MinLongitude > bbox.MaxLongitude {
+		return "Invalid bounding box"
+	}
+	if limit < 0 {
+		return "Limit must not be negative"
+	}
+	return ""
+}
+
+// requirePermission responds with 401 or 403 and returns false unless the
+// request's principal has the permission. It guards the parts of public
+// endpoints that need one, mirroring the router's per-route check.
+func requirePermission(w http.ResponseWriter, r *http.Request, perm auth.Permission) bool {
+	principal, ok := auth.PrincipalFrom(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Authentication required")
+		return false
+	}
+	if !principal.Can(perm) {
+		respondWithError(w, http.StatusForbidden, "Forbidden")
+		return false
+	}
+	return true
+}
+
+// canRead reports whether the request's principal may read station history
+// and deactivated stations.
+func canRead(r *http.Request) bool {
+	principal, ok := auth.PrincipalFrom(r.Context())
+	return ok && principal.Can(auth.PermissionStationRead)
+}
+
+// RespondWithError writes an error response in the API envelope.
+// It is used by middleware outside this package.
+func RespondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithError(w, code, message)
+}
+
+// respondWithStoreError maps a typed store error onto the matching HTTP status.
+// Untyped errors are logged with the request ID and reported as internal errors
+// without leaking their details.
+func respondWithStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, store.ErrConflict):
+		respondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, store.ErrInvalidInput):
+		respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, store.ErrForbidden):
+		respondWithError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, store.ErrTimeout):
+		slog.WarnContext(r.Context(), "store timeout", "method", r.Method, "path", r.URL.Path, "err", err)
+		respondWithError(w, http.StatusGatewayTimeout, "Request timed out")
+	default:
+		slog.ErrorContext(r.Context(), "store error", "method", r.Method, "path", r.URL.Path, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+	}
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go-https-server/internal/buildinfo"
+	"go-https-server/internal/database"
+)
+
+// readyCheckTimeout bounds the database checks of a readiness probe.
+const readyCheckTimeout = 2 * time.Second
+
+// HealthHandler serves the liveness, readiness and build-info endpoints.
+type HealthHandler struct {
+	db           *sql.DB
+	shuttingDown atomic.Bool
+}
+
+// NewHealthHandler creates a new HealthHandler.
+func NewHealthHandler(db *sql.DB) *HealthHandler {
+	return &HealthHandler{db: db}
+}
+
+// SetShuttingDown makes readiness fail so load balancers stop routing traffic.
+func (h *HealthHandler) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Live handles GET /healthz
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+}
+
+// Ready handles GET /readyz
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	if h.shuttingDown.Load() {
+		respondWithError(w, http.StatusServiceUnavailable, "Shutting down")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readyCheckTimeout)
+	defer cancel()
+
+	// The endpoint is unauthenticated, so failures are logged in detail and
+	// reported with a generic reason.
+	if err := h.db.PingContext(ctx); err != nil {
+		slog.WarnContext(ctx, "readiness: database unreachable", "err", err)
+		respondWithError(w, http.StatusServiceUnavailable, "Database unreachable")
+		return
+	}
+
+	version, err := database.CurrentVersion(ctx, h.db)
+	if err != nil {
+		slog.WarnContext(ctx, "readiness: could not read schema version", "err", err)
+		respondWithError(w, http.StatusServiceUnavailable, "Database schema unavailable")
+		return
+	}
+	// A newer schema is expected during a rolling deploy, once a newer
+	// instance has migrated, and migrations must stay compatible with the previous build.
+	if version < database.SchemaVersion {
+		slog.WarnContext(ctx, "readiness: database schema is behind", "schema_version", version, "expected", database.SchemaVersion)
+		respondWithError(w, http.StatusServiceUnavailable, "Database schema out of date")
+		return
+	}
+
+	postgis, err := database.PostGISVersion(ctx, h.db)
+	if err != nil {
+		slog.WarnContext(ctx, "readiness: PostGIS extension unavailable", "err", err)
+		respondWithError(w, http.StatusServiceUnavailable, "PostGIS extension unavailable")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status":        "ready",
+		"schemaVersion": version,
+		"postgis":       postgis,
+	})
+}
+
+// VersionRes is the response DTO of /version.
+type VersionRes struct {
+	buildinfo.Info
+	SchemaVersion int `json:"schemaVersion"`
+}
+
+// Version handles GET /version
+func (h *HealthHandler) Version(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, VersionRes{
+		Info:          buildinfo.Get(),
+		SchemaVersion: database.SchemaVersion,
+	})
+}
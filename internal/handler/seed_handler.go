@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"go-https-server/internal/database"
+	"go-https-server/internal/kml"
+)
+
+// SeedHandler handles administrative reseeding requests.
+type SeedHandler struct {
+	db      *sql.DB
+	kmzPath string
+	kmzOpts kml.KMZOptions
+}
+
+// NewSeedHandler creates a new SeedHandler that reseeds from the KMZ file at kmzPath.
+func NewSeedHandler(db *sql.DB, kmzPath string, kmzOpts kml.KMZOptions) *SeedHandler {
+	return &SeedHandler{db: db, kmzPath: kmzPath, kmzOpts: kmzOpts}
+}
+
+// BlockedSignReseedReq is the request DTO for reseeding blocked signs. The body is optional.
+type BlockedSignReseedReq struct {
+	// DryRun parses the KMZ file and reports the result without changing the table.
+	DryRun bool `json:"dryRun"`
+}
+
+// BlockedSignReseedRes reports the records inserted, or that would be
+// inserted on a dry run, the Placemarks that were rejected, what each KML
+// file contributed and the NetworkLinks that could not be followed.
+type BlockedSignReseedRes struct {
+	Count           int                  `json:"count"`
+	DryRun          bool                 `json:"dryRun"`
+	Placemarks      int                  `json:"placemarks"`
+	Rejected        []kml.Diagnostic     `json:"rejected"`
+	Sources         []kml.SourceReport   `json:"sources"`
+	UnresolvedLinks []kml.LinkDiagnostic `json:"unresolvedLinks"`
+}
+
+// ReseedBlockedSigns handles POST /api/blockedSign/reseed
+func (h *SeedHandler) ReseedBlockedSigns(w http.ResponseWriter, r *http.Request) {
+	var req BlockedSignReseedReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	var report kml.Report
+	var err error
+	if req.DryRun {
+		report, err = database.DryRunBlockedSigns(h.kmzPath, h.kmzOpts)
+	} else {
+		report, err = database.ReseedBlockedSigns(r.Context(), h.db, h.kmzPath, h.kmzOpts)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "could not reseed blocked signs", "path", h.kmzPath, "dry_run", req.DryRun, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	rejected := report.Rejected
+	if rejected == nil {
+		rejected = []kml.Diagnostic{}
+	}
+	sources := report.Sources
+	if sources == nil {
+		sources = []kml.SourceReport{}
+	}
+	unresolved := report.UnresolvedLinks
+	if unresolved == nil {
+		unresolved = []kml.LinkDiagnostic{}
+	}
+	respondWithJSON(w, http.StatusOK, BlockedSignReseedRes{
+		Count:           report.Features,
+		DryRun:          req.DryRun,
+		Placemarks:      report.Placemarks,
+		Rejected:        rejected,
+		Sources:         sources,
+		UnresolvedLinks: unresolved,
+	})
+}
@@ -0,0 +1,330 @@
+package kml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// GeometryType names a kind of KML geometry.
+type GeometryType string
+
+const (
+	GeometryPoint         GeometryType = "Point"
+	GeometryLineString    GeometryType = "LineString"
+	GeometryLinearRing    GeometryType = "LinearRing"
+	GeometryPolygon       GeometryType = "Polygon"
+	GeometryMultiGeometry GeometryType = "MultiGeometry"
+)
+
+// Geometry is a parsed KML geometry. Points, LineStrings and LinearRings use
+// Coordinates, Polygons use Rings with the outer boundary first, and
+// MultiGeometries use Geometries.
+type Geometry struct {
+	Type        GeometryType
+	Coordinates []LatLong
+	Rings       [][]LatLong
+	Geometries  []Geometry
+}
+
+// EWKT encodes the geometry as PostGIS extended well-known text with SRID
+// 4326, the text form a geography column accepts, e.g. through COPY.
+func (g Geometry) EWKT() string {
+	var b strings.Builder
+	b.WriteString("SRID=4326;")
+	g.writeWKT(&b)
+	return b.String()
+}
+
+func (g Geometry) writeWKT(b *strings.Builder) {
+	switch g.Type {
+	case GeometryPoint:
+		b.WriteString("POINT")
+		writeWKTPositions(b, g.Coordinates)
+	case GeometryLineString, GeometryLinearRing:
+		b.WriteString("LINESTRING")
+		writeWKTPositions(b, g.Coordinates)
+	case GeometryPolygon:
+		b.WriteString("POLYGON(")
+		for i, ring := range g.Rings {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeWKTPositions(b, ring)
+		}
+		b.WriteByte(')')
+	case GeometryMultiGeometry:
+		b.WriteString("GEOMETRYCOLLECTION(")
+		for i, member := range g.Geometries {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			member.writeWKT(b)
+		}
+		b.WriteByte(')')
+	}
+}
+
+// writeWKTPositions writes a parenthesised list of "longitude latitude" positions.
+func writeWKTPositions(b *strings.Builder, lls []LatLong) {
+	b.WriteByte('(')
+	for i, ll := range lls {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(ll.Longitude, 'f', -1, 64))
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatFloat(ll.Latitude, 'f', -1, 64))
+	}
+	b.WriteByte(')')
+}
+
+// Point contains the coordinates.
+type Point struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// LineString contains a path of coordinates.
+type LineString struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// LinearRing contains a closed path of coordinates.
+type LinearRing struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// Polygon contains an outer boundary and any number of inner boundaries (holes).
+type Polygon struct {
+	OuterBoundary Boundary   `xml:"outerBoundaryIs"`
+	InnerBoundary []Boundary `xml:"innerBoundaryIs"`
+}
+
+// Boundary wraps the LinearRing of a Polygon boundary.
+type Boundary struct {
+	LinearRing LinearRing `xml:"LinearRing"`
+}
+
+// MultiGeometry contains any number of geometries, including other
+// MultiGeometries, in document order.
+type MultiGeometry struct {
+	Members []MultiGeometryMember
+}
+
+// MultiGeometryMember is one geometry of a MultiGeometry. Exactly one field is set.
+type MultiGeometryMember struct {
+	Point         *Point
+	LineString    *LineString
+	LinearRing    *LinearRing
+	Polygon       *Polygon
+	MultiGeometry *MultiGeometry
+}
+
+// UnmarshalXML decodes the geometries of a MultiGeometry into a single slice,
+// keeping their order. Other child elements are skipped.
+func (m *MultiGeometry) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			var member MultiGeometryMember
+			var v interface{}
+			switch t.Name.Local {
+			case "Point":
+				member.Point = new(Point)
+				v = member.Point
+			case "LineString":
+				member.LineString = new(LineString)
+				v = member.LineString
+			case "LinearRing":
+				member.LinearRing = new(LinearRing)
+				v = member.LinearRing
+			case "Polygon":
+				member.Polygon = new(Polygon)
+				v = member.Polygon
+			case "MultiGeometry":
+				member.MultiGeometry = new(MultiGeometry)
+				v = member.MultiGeometry
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.DecodeElement(v, &t); err != nil {
+				return err
+			}
+			m.Members = append(m.Members, member)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// geometry converts a Placemark's geometry element. The error explains why
+// it has none or its coordinates are invalid.
+func (p Placemark) geometry() (Geometry, error) {
+	return MultiGeometryMember{
+		Point:         p.Point,
+		LineString:    p.LineString,
+		LinearRing:    p.LinearRing,
+		Polygon:       p.Polygon,
+		MultiGeometry: p.MultiGeometry,
+	}.geometry()
+}
+
+func (m MultiGeometryMember) geometry() (Geometry, error) {
+	switch {
+	case m.Point != nil:
+		return m.Point.geometry()
+	case m.LineString != nil:
+		return m.LineString.geometry()
+	case m.LinearRing != nil:
+		return m.LinearRing.geometry()
+	case m.Polygon != nil:
+		return m.Polygon.geometry()
+	case m.MultiGeometry != nil:
+		return m.MultiGeometry.geometry()
+	}
+	return Geometry{}, errors.New("no supported geometry")
+}
+
+func (p Point) geometry() (Geometry, error) {
+	point, err := parsePoint(p.Coordinates)
+	if err != nil {
+		return Geometry{}, fmt.Errorf("Point: %w", err)
+	}
+	return Geometry{Type: GeometryPoint, Coordinates: []LatLong{point}}, nil
+}
+
+func (l LineString) geometry() (Geometry, error) {
+	coords, err := parseCoordinates(l.Coordinates)
+	if err != nil {
+		return Geometry{}, fmt.Errorf("LineString: %w", err)
+	}
+	if len(coords) < 2 {
+		return Geometry{}, fmt.Errorf("LineString: needs at least 2 positions, got %d", len(coords))
+	}
+	return Geometry{Type: GeometryLineString, Coordinates: coords}, nil
+}
+
+func (l LinearRing) geometry() (Geometry, error) {
+	ring, err := parseRing(l.Coordinates)
+	if err != nil {
+		return Geometry{}, fmt.Errorf("LinearRing: %w", err)
+	}
+	return Geometry{Type: GeometryLinearRing, Coordinates: ring}, nil
+}
+
+func (p Polygon) geometry() (Geometry, error) {
+	outer, err := parseRing(p.OuterBoundary.LinearRing.Coordinates)
+	if err != nil {
+		return Geometry{}, fmt.Errorf("Polygon outer boundary: %w", err)
+	}
+	rings := [][]LatLong{outer}
+	for i, b := range p.InnerBoundary {
+		inner, err := parseRing(b.LinearRing.Coordinates)
+		if err != nil {
+			return Geometry{}, fmt.Errorf("Polygon inner boundary %d: %w", i+1, err)
+		}
+		rings = append(rings, inner)
+	}
+	return Geometry{Type: GeometryPolygon, Rings: rings}, nil
+}
+
+// geometry converts every member of the MultiGeometry. It fails if any member
+// is invalid or there are none.
+func (m MultiGeometry) geometry() (Geometry, error) {
+	members := make([]Geometry, 0, len(m.Members))
+	for i, member := range m.Members {
+		g, err := member.geometry()
+		if err != nil {
+			return Geometry{}, fmt.Errorf("MultiGeometry member %d: %w", i+1, err)
+		}
+		members = append(members, g)
+	}
+	if len(members) == 0 {
+		return Geometry{}, errors.New("MultiGeometry: no members")
+	}
+	return Geometry{Type: GeometryMultiGeometry, Geometries: members}, nil
+}
+
+// parsePoint parses a coordinate tuple in "longitude,latitude[,altitude]"
+// format and checks that it is within range.
+func parsePoint(coordinates string) (LatLong, error) {
+	coordsStr := strings.TrimSpace(coordinates)
+	if coordsStr == "" {
+		return LatLong{}, errors.New("empty coordinates")
+	}
+
+	parts := strings.Split(coordsStr, ",")
+	if len(parts) < 2 {
+		return LatLong{}, fmt.Errorf("coordinate %q has fewer than two components", coordsStr)
+	}
+
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return LatLong{}, fmt.Errorf("invalid longitude %q", parts[0])
+	}
+
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return LatLong{}, fmt.Errorf("invalid latitude %q", parts[1])
+	}
+
+	// ParseFloat accepts NaN and Inf, and NaN compares false with every bound.
+	if math.IsNaN(longitude) || math.IsInf(longitude, 0) {
+		return LatLong{}, fmt.Errorf("longitude %q is not a finite number", strings.TrimSpace(parts[0]))
+	}
+	if math.IsNaN(latitude) || math.IsInf(latitude, 0) {
+		return LatLong{}, fmt.Errorf("latitude %q is not a finite number", strings.TrimSpace(parts[1]))
+	}
+
+	if latitude < -90 || latitude > 90 {
+		return LatLong{}, fmt.Errorf("latitude %v out of range [-90, 90]", latitude)
+	}
+	if longitude < -180 || longitude > 180 {
+		return LatLong{}, fmt.Errorf("longitude %v out of range [-180, 180]", longitude)
+	}
+
+	return LatLong{Latitude: latitude, Longitude: longitude}, nil
+}
+
+// parseCoordinates parses a whitespace separated list of coordinate tuples.
+func parseCoordinates(coordinates string) ([]LatLong, error) {
+	tuples := strings.Fields(coordinates)
+	if len(tuples) == 0 {
+		return nil, errors.New("empty coordinates")
+	}
+	coords := make([]LatLong, 0, len(tuples))
+	for i, tuple := range tuples {
+		ll, err := parsePoint(tuple)
+		if err != nil {
+			return nil, fmt.Errorf("position %d: %w", i+1, err)
+		}
+		coords = append(coords, ll)
+	}
+	return coords, nil
+}
+
+// parseRing parses the coordinates of a LinearRing, closing it if the last
+// coordinate does not repeat the first, as PostGIS requires closed rings.
+func parseRing(coordinates string) ([]LatLong, error) {
+	ring, err := parseCoordinates(coordinates)
+	if err != nil {
+		return nil, err
+	}
+	if ring[0] != ring[len(ring)-1] {
+		ring = append(ring, ring[0])
+	}
+	if len(ring) < 4 {
+		return nil, fmt.Errorf("ring needs at least 3 distinct positions, got %d", len(ring)-1)
+	}
+	return ring, nil
+}
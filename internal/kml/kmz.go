@@ -0,0 +1,229 @@
+package kml
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// KMZOptions controls which KML files DecodeKMZ reads from an archive.
+type KMZOptions struct {
+	// MergeAll also decodes every KML file in the archive that the root
+	// document does not reach through a NetworkLink.
+	MergeAll bool
+}
+
+// DecodeKMZ decodes the root KML document of a KMZ archive with Decode. The
+// root is doc.kml by convention, otherwise the first KML file at the top of
+// the archive, otherwise the first KML file anywhere in it.
+//
+// NetworkLinks are followed to other files in the archive, and to local KML
+// and KMZ files relative to the archive's directory; the Placemarks they
+// contribute are placed under the link's folder path. Remote links are not
+// fetched and are reported as unresolved, as are links to missing files. Each
+// file is decoded at most once, so links cannot loop. Every Feature names the
+// file it came from, and the Report counts what each file contributed.
+func DecodeKMZ(kmzPath string, opts KMZOptions, fn func(Feature) error) (Report, error) {
+	d := &decoder{fn: fn}
+	l := &loader{d: d, sources: make(map[string]location), archives: make(map[string]bool)}
+	d.follow = l.follow
+
+	err := l.decodeKMZ(kmzPath, "", nil, opts.MergeAll)
+	return d.report, err
+}
+
+// loader resolves and decodes the files reachable from a KMZ archive.
+type loader struct {
+	d *decoder
+	// sources holds the location of every file decoded so far, by source name.
+	sources map[string]location
+	// archives holds the absolute path of every KMZ file opened so far,
+	// including the one passed to DecodeKMZ.
+	archives map[string]bool
+}
+
+// location is where a decoded file was read from: an archive entry or a local file.
+type location struct {
+	archive *archive
+	entry   string
+	file    string
+}
+
+// archive is an open KMZ file.
+type archive struct {
+	path string
+	// prefix is prepended to entry names to form source names. It is empty for
+	// the archive passed to DecodeKMZ.
+	prefix string
+	files  map[string]*zip.File
+}
+
+// decodeKMZ decodes the root document of the archive at kmzPath and, with
+// mergeAll, every other KML file in it.
+func (l *loader) decodeKMZ(kmzPath, prefix string, folderPath []string, mergeAll bool) error {
+	abs, err := filepath.Abs(kmzPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve KMZ file path: %w", err)
+	}
+	l.archives[abs] = true
+
+	reader, err := zip.OpenReader(kmzPath)
+	if err != nil {
+		return fmt.Errorf("failed to open KMZ file: %w", err)
+	}
+	defer reader.Close()
+
+	a := &archive{path: kmzPath, prefix: prefix, files: make(map[string]*zip.File)}
+	for _, file := range reader.File {
+		a.files[file.Name] = file
+	}
+
+	root := rootEntry(reader.File)
+	if root == "" {
+		return fmt.Errorf("no KML file found in KMZ archive %s", kmzPath)
+	}
+	if err := l.decodeEntry(a, root, folderPath); err != nil {
+		return err
+	}
+
+	if mergeAll {
+		for _, file := range reader.File {
+			if !isKML(file.Name) {
+				continue
+			}
+			if _, ok := l.sources[a.prefix+file.Name]; ok {
+				continue
+			}
+			if err := l.decodeEntry(a, file.Name, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rootEntry picks the root KML document of an archive, or returns "" if it has none.
+func rootEntry(files []*zip.File) string {
+	var first, firstTop string
+	for _, file := range files {
+		if !isKML(file.Name) {
+			continue
+		}
+		if strings.EqualFold(file.Name, "doc.kml") {
+			return file.Name
+		}
+		if first == "" {
+			first = file.Name
+		}
+		if firstTop == "" && !strings.Contains(file.Name, "/") {
+			firstTop = file.Name
+		}
+	}
+	if firstTop != "" {
+		return firstTop
+	}
+	return first
+}
+
+func isKML(name string) bool {
+	return strings.EqualFold(path.Ext(name), ".kml")
+}
+
+func (l *loader) decodeEntry(a *archive, entry string, folderPath []string) error {
+	source := a.prefix + entry
+	l.sources[source] = location{archive: a, entry: entry}
+
+	rc, err := a.files[entry].Open()
+	if err != nil {
+		return fmt.Errorf("failed to open KML file %s from archive: %w", entry, err)
+	}
+	defer rc.Close()
+
+	return l.d.decode(rc, source, folderPath)
+}
+
+func (l *loader) decodeFile(file string, folderPath []string) error {
+	l.sources[file] = location{file: file}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to open KML file: %w", err)
+	}
+	defer f.Close()
+
+	return l.d.decode(f, file, folderPath)
+}
+
+// follow resolves the href of a NetworkLink found in source, first inside
+// source's archive and then on disk, and decodes its target.
+func (l *loader) follow(source, href string, folderPath []string) error {
+	from := l.sources[source]
+
+	target, _, _ := strings.Cut(href, "#")
+	if target == "" {
+		l.d.unresolved(source, href, "links to features in the same file are not followed")
+		return nil
+	}
+	if u, err := url.Parse(target); err == nil && u.Scheme != "" && len(u.Scheme) > 1 {
+		if u.Scheme != "file" {
+			l.d.unresolved(source, href, "remote links are not followed")
+			return nil
+		}
+		target = u.Path
+	} else if unescaped, err := url.PathUnescape(target); err == nil {
+		// Relative hrefs are URL references, so spaces and the like arrive percent-encoded.
+		target = unescaped
+	}
+
+	if from.archive != nil && !path.IsAbs(target) {
+		entry := path.Join(path.Dir(from.entry), target)
+		if _, ok := from.archive.files[entry]; ok {
+			if _, done := l.sources[from.archive.prefix+entry]; done {
+				l.d.unresolved(source, href, "already decoded")
+				return nil
+			}
+			return l.decodeEntry(from.archive, entry, folderPath)
+		}
+	}
+
+	local := filepath.FromSlash(target)
+	if !filepath.IsAbs(local) {
+		base := from.file
+		if from.archive != nil {
+			base = from.archive.path
+		}
+		local = filepath.Join(filepath.Dir(base), local)
+	}
+
+	switch {
+	case isKML(local):
+		if _, done := l.sources[local]; done {
+			l.d.unresolved(source, href, "already decoded")
+			return nil
+		}
+		if _, err := os.Stat(local); errors.Is(err, fs.ErrNotExist) {
+			l.d.unresolved(source, href, "not found in the archive or on disk")
+			return nil
+		}
+		return l.decodeFile(local, folderPath)
+	case strings.EqualFold(filepath.Ext(local), ".kmz"):
+		if abs, err := filepath.Abs(local); err == nil && l.archives[abs] {
+			l.d.unresolved(source, href, "already decoded")
+			return nil
+		}
+		if _, err := os.Stat(local); errors.Is(err, fs.ErrNotExist) {
+			l.d.unresolved(source, href, "not found in the archive or on disk")
+			return nil
+		}
+		return l.decodeKMZ(local, local+":", folderPath, false)
+	default:
+		l.d.unresolved(source, href, "not a KML or KMZ file")
+		return nil
+	}
+}
@@ -0,0 +1,234 @@
+package kml
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// entry is a file to store in a test archive.
+type entry struct {
+	name, content string
+}
+
+func writeKMZ(t *testing.T, path string, entries ...entry) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// placemark returns a KML document with a single named Point Placemark.
+func placemark(name string) string {
+	return `<kml><Placemark><name>` + name + `</name><Point><coordinates>1,2</coordinates></Point></Placemark></kml>`
+}
+
+// link returns a KML document that only holds a NetworkLink to href.
+func link(name, href string) string {
+	return `<kml><NetworkLink><name>` + name + `</name><Link><href>` + href + `</href></Link></NetworkLink></kml>`
+}
+
+func TestRootEntry(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"doc.kml", []string{"a.kml", "doc.kml", "b.kml"}, "doc.kml"},
+		{"doc.kml in any case", []string{"a.kml", "DOC.KML"}, "DOC.KML"},
+		{"nested doc.kml is not the root", []string{"files/doc.kml", "a.kml"}, "a.kml"},
+		{"first top-level file", []string{"files/x.kml", "b.kml", "a.kml"}, "b.kml"},
+		{"first nested file", []string{"images/icon.png", "files/x.kml", "files/y.kml"}, "files/x.kml"},
+		{"upper case extension", []string{"A.KML"}, "A.KML"},
+		{"no kml", []string{"images/icon.png"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := make([]*zip.File, len(tt.files))
+			for i, name := range tt.files {
+				files[i] = &zip.File{FileHeader: zip.FileHeader{Name: name}}
+			}
+			if got := rootEntry(files); got != tt.want {
+				t.Errorf("rootEntry() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// decodeKMZ decodes the archive at path and returns the name and source of
+// every Feature, in order.
+func decodeKMZ(t *testing.T, path string, opts KMZOptions) ([][2]string, Report) {
+	t.Helper()
+	var got [][2]string
+	report, err := DecodeKMZ(path, opts, func(f Feature) error {
+		got = append(got, [2]string{f.Name, f.Source})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DecodeKMZ: %v", err)
+	}
+	return got, report
+}
+
+func TestDecodeKMZFollowsLinksInArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signs.kmz")
+	writeKMZ(t, path,
+		entry{"extra.kml", placemark("extra")},
+		entry{"files/a.kml", `<kml>
+			<Placemark><name>a</name><Point><coordinates>1,2</coordinates></Point></Placemark>
+			<NetworkLink><Link><href>b%20c.kml</href></Link></NetworkLink>
+		</kml>`},
+		entry{"files/b c.kml", placemark("b")},
+		entry{"doc.kml", `<kml><Folder><name>Roads</name>
+			<NetworkLink><name>North</name><Link><href>files/a.kml#layer</href></Link></NetworkLink>
+		</Folder></kml>`},
+	)
+
+	var folders [][]string
+	report, err := DecodeKMZ(path, KMZOptions{}, func(f Feature) error {
+		folders = append(folders, f.FolderPath)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DecodeKMZ: %v", err)
+	}
+	wantFolders := [][]string{{"Roads", "North"}, {"Roads", "North"}}
+	if !reflect.DeepEqual(folders, wantFolders) {
+		t.Errorf("folder paths = %v, want %v", folders, wantFolders)
+	}
+	wantSources := []SourceReport{
+		{File: "doc.kml"},
+		{File: "files/a.kml", Placemarks: 1, Features: 1},
+		{File: "files/b c.kml", Placemarks: 1, Features: 1},
+	}
+	if !reflect.DeepEqual(report.Sources, wantSources) {
+		t.Errorf("sources:\n got %+v\nwant %+v", report.Sources, wantSources)
+	}
+
+	got, _ := decodeKMZ(t, path, KMZOptions{MergeAll: true})
+	want := [][2]string{{"a", "files/a.kml"}, {"b", "files/b c.kml"}, {"extra", "extra.kml"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged features = %v, want %v", got, want)
+	}
+}
+
+func TestDecodeKMZFollowsLocalFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeKMZ(t, filepath.Join(dir, "signs.kmz"), entry{"doc.kml", link("", "layers/local.kml")})
+	if err := os.Mkdir(filepath.Join(dir, "layers"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	local := filepath.Join(dir, "layers", "local.kml")
+	if err := os.WriteFile(local, []byte(`<kml>
+		<Placemark><name>local</name><Point><coordinates>1,2</coordinates></Point></Placemark>
+		<NetworkLink><Link><href>../other.kmz</href></Link></NetworkLink>
+	</kml>`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "other.kmz")
+	writeKMZ(t, other, entry{"doc.kml", placemark("other")})
+
+	got, report := decodeKMZ(t, filepath.Join(dir, "signs.kmz"), KMZOptions{})
+	want := [][2]string{{"local", local}, {"other", other + ":doc.kml"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("features = %v, want %v", got, want)
+	}
+	if len(report.UnresolvedLinks) != 0 {
+		t.Errorf("unexpected unresolved links %+v", report.UnresolvedLinks)
+	}
+}
+
+func TestDecodeKMZReportsUnresolvedLinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signs.kmz")
+	writeKMZ(t, path,
+		entry{"doc.kml", `<kml>
+			<NetworkLink><Link><href>a.kml</href></Link></NetworkLink>
+			<NetworkLink><Link><href>https://example.com/remote.kml</href></Link></NetworkLink>
+			<NetworkLink><Url><href>missing.kml</href></Url></NetworkLink>
+			<NetworkLink><Link><href>#feature</href></Link></NetworkLink>
+			<NetworkLink><Link><href>image.png</href></Link></NetworkLink>
+		</kml>`},
+		// a.kml and b.kml link to each other and back to the root.
+		entry{"a.kml", `<kml>
+			<Placemark><name>a</name><Point><coordinates>1,2</coordinates></Point></Placemark>
+			<NetworkLink><Link><href>b.kml</href></Link></NetworkLink>
+			<NetworkLink><Link><href>doc.kml</href></Link></NetworkLink>
+		</kml>`},
+		entry{"b.kml", `<kml>
+			<Placemark><name>b</name><Point><coordinates>1,2</coordinates></Point></Placemark>
+			<NetworkLink><Link><href>a.kml</href></Link></NetworkLink>
+		</kml>`},
+	)
+
+	got, report := decodeKMZ(t, path, KMZOptions{})
+	want := [][2]string{{"a", "a.kml"}, {"b", "b.kml"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("features = %v, want %v", got, want)
+	}
+	wantLinks := []LinkDiagnostic{
+		{Source: "b.kml", Href: "a.kml", Reason: "already decoded"},
+		{Source: "a.kml", Href: "doc.kml", Reason: "already decoded"},
+		{Source: "doc.kml", Href: "https://example.com/remote.kml", Reason: "remote links are not followed"},
+		{Source: "doc.kml", Href: "missing.kml", Reason: "not found in the archive or on disk"},
+		{Source: "doc.kml", Href: "#feature", Reason: "links to features in the same file are not followed"},
+		{Source: "doc.kml", Href: "image.png", Reason: "not a KML or KMZ file"},
+	}
+	if !reflect.DeepEqual(report.UnresolvedLinks, wantLinks) {
+		t.Errorf("unresolved links:\n got %+v\nwant %+v", report.UnresolvedLinks, wantLinks)
+	}
+}
+
+func TestDecodeKMZSkipsLinksBackToArchives(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root.kmz")
+	writeKMZ(t, root, entry{"doc.kml", `<kml>
+		<Placemark><name>a</name><Point><coordinates>1,2</coordinates></Point></Placemark>
+		<NetworkLink><Link><href>root.kmz</href></Link></NetworkLink>
+		<NetworkLink><Link><href>other.kmz</href></Link></NetworkLink>
+	</kml>`})
+	other := filepath.Join(dir, "other.kmz")
+	writeKMZ(t, other, entry{"doc.kml", `<kml>
+		<Placemark><name>b</name><Point><coordinates>1,2</coordinates></Point></Placemark>
+		<NetworkLink><Link><href>root.kmz</href></Link></NetworkLink>
+		<NetworkLink><Link><href>./other.kmz</href></Link></NetworkLink>
+	</kml>`})
+
+	got, report := decodeKMZ(t, root, KMZOptions{})
+	want := [][2]string{{"a", "doc.kml"}, {"b", other + ":doc.kml"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("features = %v, want %v", got, want)
+	}
+	wantLinks := []LinkDiagnostic{
+		{Source: "doc.kml", Href: "root.kmz", Reason: "already decoded"},
+		{Source: other + ":doc.kml", Href: "root.kmz", Reason: "already decoded"},
+		{Source: other + ":doc.kml", Href: "./other.kmz", Reason: "already decoded"},
+	}
+	if !reflect.DeepEqual(report.UnresolvedLinks, wantLinks) {
+		t.Errorf("unresolved links:\n got %+v\nwant %+v", report.UnresolvedLinks, wantLinks)
+	}
+}
+
+func TestDecodeKMZWithoutKML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.kmz")
+	writeKMZ(t, path, entry{"images/icon.png", ""})
+	if _, err := DecodeKMZ(path, KMZOptions{}, func(Feature) error { return nil }); err == nil {
+		t.Error("expected an error for an archive without KML files")
+	}
+}
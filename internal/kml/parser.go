@@ -1,12 +1,10 @@
 package kml
 
 import (
-	"archive/zip"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"strings"
-	"strconv"
 )
 
 // LatLong represents a latitude-longitude coordinate pair.
@@ -15,108 +13,261 @@ type LatLong struct {
 	Longitude float64
 }
 
-// kml is the root element of a KML file.
-type kml struct {
-	Document Document `xml:"Document"`
+// Feature is a Placemark with its location and metadata.
+type Feature struct {
+	Name        string
+	Description string
+	StyleURL    string
+	// ExtendedData holds the Placemark's <Data> values and <SchemaData>
+	// <SimpleData> fields by name.
+	ExtendedData map[string]string
+	// FolderPath holds the names of the Folders enclosing the Placemark, outermost first.
+	FolderPath []string
+	Geometry   Geometry
+	// Source names the KML file the Placemark was read from.
+	Source string
 }
 
-// Document contains a list of Placemarks.
-type Document struct {
-	Placemarks []Placemark `xml:"Placemark"`
-	Folders    []Folder    `xml:"Folder"`
+// Diagnostic records a Placemark that was rejected while decoding.
+type Diagnostic struct {
+	Source string `json:"source"`
+	// Index is the Placemark's zero-based position among all Placemarks in its source file.
+	Index      int      `json:"index"`
+	Line       int      `json:"line"`
+	FolderPath []string `json:"folderPath"`
+	Name       string   `json:"name"`
+	Reason     string   `json:"reason"`
 }
 
-// Folder contains a list of Placemarks and other Folders.
-type Folder struct {
-	Placemarks []Placemark `xml:"Placemark"`
-	Folders    []Folder    `xml:"Folder"`
+// Report summarises a decode.
+type Report struct {
+	// Placemarks counts every Placemark seen; Features counts those passed to the callback.
+	Placemarks int          `json:"placemarks"`
+	Features   int          `json:"features"`
+	Rejected   []Diagnostic `json:"rejected"`
+	// Sources lists the KML files decoded, in order, with what each contributed.
+	Sources []SourceReport `json:"sources"`
+	// UnresolvedLinks lists the NetworkLinks that could not be followed.
+	UnresolvedLinks []LinkDiagnostic `json:"unresolvedLinks"`
 }
 
-// Placemark contains a Point.
-type Placemark struct {
-	Point Point `xml:"Point"`
+// SourceReport counts the Placemarks read from one KML file.
+type SourceReport struct {
+	File       string `json:"file"`
+	Placemarks int    `json:"placemarks"`
+	Features   int    `json:"features"`
+	Rejected   int    `json:"rejected"`
 }
 
-// Point contains the coordinates.
-type Point struct {
-	Coordinates string `xml:"coordinates"`
+// LinkDiagnostic records a NetworkLink that could not be followed.
+type LinkDiagnostic struct {
+	Source string `json:"source"`
+	Href   string `json:"href"`
+	Reason string `json:"reason"`
 }
 
-// ParseKMZ reads a KMZ file, extracts the KML file, and parses the coordinates.
-// It assumes the KML file has Placemarks with Point coordinates in "longitude,latitude,altitude" format.
-func ParseKMZ(kmzPath string) ([]LatLong, error) {
-	reader, err := zip.OpenReader(kmzPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open KMZ file: %w", err)
-	}
-	defer reader.Close()
+// NetworkLink references another KML file. Older files use Url instead of Link.
+type NetworkLink struct {
+	Name string `xml:"name"`
+	Link struct {
+		Href string `xml:"href"`
+	} `xml:"Link"`
+	URL struct {
+		Href string `xml:"href"`
+	} `xml:"Url"`
+}
 
-	var kmlFile *zip.File
-	for _, file := range reader.File {
-		if strings.HasSuffix(strings.ToLower(file.Name), ".kml") {
-			kmlFile = file
-			break
-		}
+func (n NetworkLink) href() string {
+	if href := strings.TrimSpace(n.Link.Href); href != "" {
+		return href
 	}
+	return strings.TrimSpace(n.URL.Href)
+}
 
-	if kmlFile == nil {
-		return nil, fmt.Errorf("no KML file found in KMZ archive")
-	}
+// Placemark contains a single geometry and its metadata.
+type Placemark struct {
+	Name          string         `xml:"name"`
+	Description   string         `xml:"description"`
+	StyleURL      string         `xml:"styleUrl"`
+	ExtendedData  ExtendedData   `xml:"ExtendedData"`
+	Point         *Point         `xml:"Point"`
+	LineString    *LineString    `xml:"LineString"`
+	LinearRing    *LinearRing    `xml:"LinearRing"`
+	Polygon       *Polygon       `xml:"Polygon"`
+	MultiGeometry *MultiGeometry `xml:"MultiGeometry"`
+}
 
-	rc, err := kmlFile.Open()
-	if err != nil {
-		return nil, fmt.Errorf("failed to open KML file from archive: %w", err)
-	}
-	defer rc.Close()
+// ExtendedData contains untyped Data pairs and typed SchemaData fields.
+type ExtendedData struct {
+	Data       []Data       `xml:"Data"`
+	SchemaData []SchemaData `xml:"SchemaData"`
+}
 
-	return parseKML(rc)
+// Data is a name/value pair.
+type Data struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
 }
 
-func parseKML(reader io.Reader) ([]LatLong, error) {
-	var kmlData kml
-	if err := xml.NewDecoder(reader).Decode(&kmlData); err != nil {
-		return nil, fmt.Errorf("failed to decode KML: %w", err)
-	}
+// SchemaData contains fields declared by a Schema.
+type SchemaData struct {
+	SchemaURL  string       `xml:"schemaUrl,attr"`
+	SimpleData []SimpleData `xml:"SimpleData"`
+}
 
-	var placemarks []Placemark
-	placemarks = append(placemarks, kmlData.Document.Placemarks...)
+// SimpleData is a single Schema field value.
+type SimpleData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
 
-	var collectPlacemarks func(folders []Folder)
-	collectPlacemarks = func(folders []Folder) {
-		for _, folder := range folders {
-			placemarks = append(placemarks, folder.Placemarks...)
-			collectPlacemarks(folder.Folders)
+// values flattens the extended data into a map, or returns nil if there is none.
+func (e ExtendedData) values() map[string]string {
+	if len(e.Data) == 0 && len(e.SchemaData) == 0 {
+		return nil
+	}
+	values := make(map[string]string)
+	for _, d := range e.Data {
+		values[d.Name] = strings.TrimSpace(d.Value)
+	}
+	for _, sd := range e.SchemaData {
+		for _, d := range sd.SimpleData {
+			values[d.Name] = strings.TrimSpace(d.Value)
 		}
 	}
-	collectPlacemarks(kmlData.Document.Folders)
+	return values
+}
 
-	var latLongs []LatLong
-	for _, placemark := range placemarks {
-		coordsStr := strings.TrimSpace(placemark.Point.Coordinates)
-		if coordsStr == "" {
-			continue
-		}
+// Decode reads a KML document token by token and calls fn with each Placemark
+// that has a valid geometry, in document order. Only the current Placemark is
+// held in memory, so documents of any size can be decoded. Coordinates are
+// expected in "longitude,latitude[,altitude]" format; altitudes are dropped.
+// Placemarks without a valid geometry are recorded in the returned Report
+// rather than failing the decode. NetworkLinks are not followed; use DecodeKMZ
+// for that. Decoding stops at the first error returned by fn.
+func Decode(r io.Reader, fn func(Feature) error) (Report, error) {
+	d := &decoder{fn: fn}
+	err := d.decode(r, "", nil)
+	return d.report, err
+}
 
-		parts := strings.Split(coordsStr, ",")
-		if len(parts) < 2 {
-			continue
-		}
+// decoder decodes one or more KML documents into a shared Report.
+type decoder struct {
+	report Report
+	fn     func(Feature) error
+	// follow decodes the target of a NetworkLink found in source, with the
+	// Placemarks under folderPath. When nil, links are reported as unresolved.
+	follow func(source, href string, folderPath []string) error
+}
 
-		longitude, err := strconv.ParseFloat(parts[0], 64)
-		if err != nil {
-			continue
-		}
+// unresolved records a NetworkLink that could not be followed.
+func (d *decoder) unresolved(source, href, reason string) {
+	d.report.UnresolvedLinks = append(d.report.UnresolvedLinks, LinkDiagnostic{Source: source, Href: href, Reason: reason})
+}
+
+// decode streams the KML document in r, named source, prefixing the folder
+// path of each Placemark with folderPath.
+func (d *decoder) decode(r io.Reader, source string, folderPath []string) error {
+	dec := xml.NewDecoder(r)
 
-		latitude, err := strconv.ParseFloat(parts[1], 64)
+	d.report.Sources = append(d.report.Sources, SourceReport{File: source})
+	sourceIndex := len(d.report.Sources) - 1
+	placemarks := 0
+
+	var open []string                               // local names of the enclosing elements
+	folders := append([]string(nil), folderPath...) // names of the enclosing Folders
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
 		if err != nil {
-			continue
+			return fmt.Errorf("failed to decode KML %s: %w", source, err)
 		}
 
-		latLongs = append(latLongs, LatLong{
-			Latitude:  latitude,
-			Longitude: longitude,
-		})
-	}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "Placemark":
+				line, _ := dec.InputPos()
+				var placemark Placemark
+				if err := dec.DecodeElement(&placemark, &t); err != nil {
+					return fmt.Errorf("failed to decode KML Placemark in %s on line %d: %w", source, line, err)
+				}
+				index := placemarks
+				placemarks++
+				d.report.Placemarks++
+				d.report.Sources[sourceIndex].Placemarks++
 
-	return latLongs, nil
+				name := strings.TrimSpace(placemark.Name)
+				path := append([]string(nil), folders...)
+				geometry, err := placemark.geometry()
+				if err != nil {
+					d.report.Rejected = append(d.report.Rejected, Diagnostic{
+						Source:     source,
+						Index:      index,
+						Line:       line,
+						FolderPath: path,
+						Name:       name,
+						Reason:     err.Error(),
+					})
+					d.report.Sources[sourceIndex].Rejected++
+					continue
+				}
+				err = d.fn(Feature{
+					Name:         name,
+					Description:  strings.TrimSpace(placemark.Description),
+					StyleURL:     strings.TrimSpace(placemark.StyleURL),
+					ExtendedData: placemark.ExtendedData.values(),
+					FolderPath:   path,
+					Geometry:     geometry,
+					Source:       source,
+				})
+				if err != nil {
+					return err
+				}
+				d.report.Features++
+				d.report.Sources[sourceIndex].Features++
+				continue
+			case "NetworkLink":
+				var link NetworkLink
+				if err := dec.DecodeElement(&link, &t); err != nil {
+					return fmt.Errorf("failed to decode KML NetworkLink in %s: %w", source, err)
+				}
+				href := link.href()
+				switch {
+				case href == "":
+					d.unresolved(source, href, "no href")
+				case d.follow == nil:
+					d.unresolved(source, href, "links are only followed in KMZ files")
+				default:
+					path := append([]string(nil), folders...)
+					if name := strings.TrimSpace(link.Name); name != "" {
+						path = append(path, name)
+					}
+					if err := d.follow(source, href, path); err != nil {
+						return err
+					}
+				}
+				continue
+			case "name":
+				if len(open) > 0 && open[len(open)-1] == "Folder" {
+					var name string
+					if err := dec.DecodeElement(&name, &t); err != nil {
+						return fmt.Errorf("failed to decode KML Folder name in %s: %w", source, err)
+					}
+					folders[len(folders)-1] = strings.TrimSpace(name)
+					continue
+				}
+			case "Folder":
+				folders = append(folders, "")
+			}
+			open = append(open, t.Name.Local)
+		case xml.EndElement:
+			open = open[:len(open)-1]
+			if t.Name.Local == "Folder" {
+				folders = folders[:len(folders)-1]
+			}
+		}
+	}
 }
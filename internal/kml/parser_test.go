@@ -0,0 +1,204 @@
+package kml
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func point(lat, lon float64) Geometry {
+	return Geometry{Type: GeometryPoint, Coordinates: []LatLong{{Latitude: lat, Longitude: lon}}}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name         string
+		doc          string
+		want         []Feature
+		wantRejected []Diagnostic
+	}{
+		{
+			name: "point metadata",
+			doc: `<kml><Document><Placemark>
+				<name> Sign </name><description>No entry</description><styleUrl>#red</styleUrl>
+				<Point><coordinates>114.1,22.3,10</coordinates></Point>
+			</Placemark></Document></kml>`,
+			want: []Feature{{Name: "Sign", Description: "No entry", StyleURL: "#red", Geometry: point(22.3, 114.1)}},
+		},
+		{
+			name: "nested folders",
+			doc: `<kml><Document>
+				<Folder><name>District</name>
+					<Folder><name>Street</name>
+						<Placemark><name>a</name><Point><coordinates>1,2</coordinates></Point></Placemark>
+					</Folder>
+					<Placemark><name>b</name><Point><coordinates>3,4</coordinates></Point></Placemark>
+				</Folder>
+				<Folder>
+					<Placemark><name>c</name><Point><coordinates>5,6</coordinates></Point></Placemark>
+				</Folder>
+				<Placemark><name>d</name><Point><coordinates>7,8</coordinates></Point></Placemark>
+			</Document></kml>`,
+			want: []Feature{
+				{Name: "a", FolderPath: []string{"District", "Street"}, Geometry: point(2, 1)},
+				{Name: "b", FolderPath: []string{"District"}, Geometry: point(4, 3)},
+				{Name: "c", FolderPath: []string{""}, Geometry: point(6, 5)},
+				{Name: "d", Geometry: point(8, 7)},
+			},
+		},
+		{
+			name: "extended data",
+			doc: `<kml><Placemark>
+				<ExtendedData>
+					<Data name="road"><value> Nathan Road </value></Data>
+					<SchemaData schemaUrl="#s"><SimpleData name="limit">3.5t</SimpleData></SchemaData>
+				</ExtendedData>
+				<Point><coordinates>1,2</coordinates></Point>
+			</Placemark></kml>`,
+			want: []Feature{{
+				ExtendedData: map[string]string{"road": "Nathan Road", "limit": "3.5t"},
+				Geometry:     point(2, 1),
+			}},
+		},
+		{
+			name: "line string",
+			doc:  `<kml><Placemark><LineString><coordinates>1,2 3,4,5</coordinates></LineString></Placemark></kml>`,
+			want: []Feature{{Geometry: Geometry{Type: GeometryLineString, Coordinates: []LatLong{{2, 1}, {4, 3}}}}},
+		},
+		{
+			name: "linear ring is closed",
+			doc:  `<kml><Placemark><LinearRing><coordinates>0,0 1,0 1,1</coordinates></LinearRing></Placemark></kml>`,
+			want: []Feature{{Geometry: Geometry{Type: GeometryLinearRing, Coordinates: []LatLong{{0, 0}, {0, 1}, {1, 1}, {0, 0}}}}},
+		},
+		{
+			name: "polygon with hole",
+			doc: `<kml><Placemark><Polygon>
+				<outerBoundaryIs><LinearRing><coordinates>0,0 4,0 4,4 0,4 0,0</coordinates></LinearRing></outerBoundaryIs>
+				<innerBoundaryIs><LinearRing><coordinates>1,1 2,1 2,2</coordinates></LinearRing></innerBoundaryIs>
+			</Polygon></Placemark></kml>`,
+			want: []Feature{{Geometry: Geometry{Type: GeometryPolygon, Rings: [][]LatLong{
+				{{0, 0}, {0, 4}, {4, 4}, {4, 0}, {0, 0}},
+				{{1, 1}, {1, 2}, {2, 2}, {1, 1}},
+			}}}},
+		},
+		{
+			name: "multi geometry keeps document order",
+			doc: `<kml><Placemark><MultiGeometry>
+				<LineString><coordinates>1,2 3,4</coordinates></LineString>
+				<Point><coordinates>5,6</coordinates></Point>
+				<MultiGeometry><Point><coordinates>7,8</coordinates></Point></MultiGeometry>
+			</MultiGeometry></Placemark></kml>`,
+			want: []Feature{{Geometry: Geometry{Type: GeometryMultiGeometry, Geometries: []Geometry{
+				{Type: GeometryLineString, Coordinates: []LatLong{{2, 1}, {4, 3}}},
+				point(6, 5),
+				{Type: GeometryMultiGeometry, Geometries: []Geometry{point(8, 7)}},
+			}}}},
+		},
+		{
+			name: "malformed coordinates",
+			doc: `<kml>
+<Folder><name>Bad</name>
+<Placemark><name>empty</name><Point><coordinates> </coordinates></Point></Placemark>
+<Placemark><name>ok</name><Point><coordinates>1,2</coordinates></Point></Placemark>
+<Placemark><name>one component</name><Point><coordinates>1</coordinates></Point></Placemark>
+</Folder>
+<Placemark><name>latitude</name><Point><coordinates>1,91</coordinates></Point></Placemark>
+<Placemark><name>nan</name><Point><coordinates>NaN,1</coordinates></Point></Placemark>
+<Placemark><name>short line</name><LineString><coordinates>1,2</coordinates></LineString></Placemark>
+<Placemark><name>small ring</name><LinearRing><coordinates>0,0 1,1</coordinates></LinearRing></Placemark>
+<Placemark><name>bad member</name><MultiGeometry><Point><coordinates>1,2</coordinates></Point><Point><coordinates>x,2</coordinates></Point></MultiGeometry></Placemark>
+<Placemark><name>none</name></Placemark>
+</kml>`,
+			want: []Feature{{Name: "ok", FolderPath: []string{"Bad"}, Geometry: point(2, 1)}},
+			wantRejected: []Diagnostic{
+				{Index: 0, Line: 3, FolderPath: []string{"Bad"}, Name: "empty", Reason: "Point: empty coordinates"},
+				{Index: 2, Line: 5, FolderPath: []string{"Bad"}, Name: "one component", Reason: `Point: coordinate "1" has fewer than two components`},
+				{Index: 3, Line: 7, Name: "latitude", Reason: "Point: latitude 91 out of range [-90, 90]"},
+				{Index: 4, Line: 8, Name: "nan", Reason: `Point: longitude "NaN" is not a finite number`},
+				{Index: 5, Line: 9, Name: "short line", Reason: "LineString: needs at least 2 positions, got 1"},
+				{Index: 6, Line: 10, Name: "small ring", Reason: "LinearRing: ring needs at least 3 distinct positions, got 2"},
+				{Index: 7, Line: 11, Name: "bad member", Reason: `MultiGeometry member 2: Point: invalid longitude "x"`},
+				{Index: 8, Line: 12, Name: "none", Reason: "no supported geometry"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Feature
+			report, err := Decode(strings.NewReader(tt.doc), func(f Feature) error {
+				got = append(got, f)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("features:\n got %+v\nwant %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(report.Rejected, tt.wantRejected) {
+				t.Errorf("rejected:\n got %+v\nwant %+v", report.Rejected, tt.wantRejected)
+			}
+			if report.Placemarks != len(tt.want)+len(tt.wantRejected) || report.Features != len(tt.want) {
+				t.Errorf("report counts %d placemarks, %d features; want %d, %d",
+					report.Placemarks, report.Features, len(tt.want)+len(tt.wantRejected), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestDecodeStopsOnCallbackError(t *testing.T) {
+	doc := `<kml>
+		<Placemark><Point><coordinates>1,2</coordinates></Point></Placemark>
+		<Placemark><Point><coordinates>3,4</coordinates></Point></Placemark>
+	</kml>`
+	stop := errors.New("stop")
+	calls := 0
+	_, err := Decode(strings.NewReader(doc), func(Feature) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("got err %v after %d calls, want %v after 1", err, calls, stop)
+	}
+}
+
+func TestDecodeMalformedXML(t *testing.T) {
+	_, err := Decode(strings.NewReader(`<kml><Placemark><name>x</Placemark></kml>`), func(Feature) error { return nil })
+	if err == nil {
+		t.Error("expected an error for malformed XML")
+	}
+}
+
+func TestDecodeReportsNetworkLinks(t *testing.T) {
+	doc := `<kml><NetworkLink><Link><href>other.kml</href></Link></NetworkLink><NetworkLink/></kml>`
+	report, err := Decode(strings.NewReader(doc), func(Feature) error { return nil })
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	want := []LinkDiagnostic{
+		{Href: "other.kml", Reason: "links are only followed in KMZ files"},
+		{Reason: "no href"},
+	}
+	if !reflect.DeepEqual(report.UnresolvedLinks, want) {
+		t.Errorf("unresolved links:\n got %+v\nwant %+v", report.UnresolvedLinks, want)
+	}
+}
+
+func TestGeometryEWKT(t *testing.T) {
+	tests := []struct {
+		geometry Geometry
+		want     string
+	}{
+		{point(22.3, 114.1), "SRID=4326;POINT(114.1 22.3)"},
+		{Geometry{Type: GeometryLinearRing, Coordinates: []LatLong{{0, 0}, {0, 1}, {1, 1}, {0, 0}}}, "SRID=4326;LINESTRING(0 0,1 0,1 1,0 0)"},
+		{Geometry{Type: GeometryPolygon, Rings: [][]LatLong{{{0, 0}, {0, 1}, {1, 1}, {0, 0}}}}, "SRID=4326;POLYGON((0 0,1 0,1 1,0 0))"},
+		{Geometry{Type: GeometryMultiGeometry, Geometries: []Geometry{point(2, 1), point(4, 3)}}, "SRID=4326;GEOMETRYCOLLECTION(POINT(1 2),POINT(3 4))"},
+	}
+	for _, tt := range tests {
+		if got := tt.geometry.EWKT(); got != tt.want {
+			t.Errorf("EWKT() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -1,12 +1,101 @@
 package logger
 
 import (
+	"context"
 	"log"
+	"log/slog"
 	"os"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Init configures the standard logger to output to stdout with date, time, and file name.
+// level is the minimum level of the default logger and can be changed at runtime.
+var level = new(slog.LevelVar)
+
+// Init configures slog to write JSON to stdout and routes the standard logger through it.
 func Init() {
-	log.SetOutput(os.Stdout)
-	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     level,
+	})
+	slog.SetDefault(slog.New(&contextHandler{Handler: handler}))
+	log.SetFlags(0)
+}
+
+// SetLevel sets the minimum log level from its name: debug, info, warn or error.
+func SetLevel(name string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return err
+	}
+	level.Set(l)
+	return nil
+}
+
+type requestInfoKey struct{}
+
+// requestInfo carries per-request log attributes. The user is filled in by the
+// authentication middleware, after the access log middleware created it.
+type requestInfo struct {
+	id string
+
+	mu   sync.Mutex
+	user string
+}
+
+// WithRequestID returns a copy of ctx whose log records carry the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, &requestInfo{id: id})
+}
+
+// RequestID returns the request ID stored in ctx, if any.
+func RequestID(ctx context.Context) string {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		return info.id
+	}
+	return ""
+}
+
+// SetUser records the authenticated user of the request in ctx.
+func SetUser(ctx context.Context, user string) {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		info.mu.Lock()
+		info.user = user
+		info.mu.Unlock()
+	}
+}
+
+// User returns the user recorded with SetUser, if any.
+func User(ctx context.Context) string {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		info.mu.Lock()
+		defer info.mu.Unlock()
+		return info.user
+	}
+	return ""
+}
+
+// contextHandler adds the request ID and trace ID from the context to every
+// record logged with one of the slog *Context functions.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
 }
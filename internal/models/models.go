@@ -1,15 +1,28 @@
 package models
 
 import (
+	"encoding/json"
+
 	"github.com/lib/pq"
 	"time"
 )
 
-// BlockedSign represents a blocked sign location.
+// BlockedSign represents a blocked sign, restricted road or closure zone and
+// the metadata of the KML Placemark it was seeded from. Geometry is GeoJSON;
+// Latitude and Longitude are the point itself, or a point on the line or
+// polygon for other geometries.
 type BlockedSign struct {
-	ID        int     `json:"id"`
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
+	ID          int               `json:"id"`
+	Latitude    float64           `json:"latitude"`
+	Longitude   float64           `json:"longitude"`
+	Geometry    json.RawMessage   `json:"geometry"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	StyleURL    string            `json:"styleUrl"`
+	FolderPath  pq.StringArray    `json:"folderPath"`
+	Properties  map[string]string `json:"properties"`
+	// SourceFile names the KML file the sign was seeded from.
+	SourceFile string `json:"sourceFile"`
 }
 
 // Station represents a station point location.
@@ -24,3 +37,50 @@ type Station struct {
 	IsActive  bool           `json:"isActive"`
 	Tags      pq.StringArray `json:"tags"`
 }
+
+// StationWithDistance is a station annotated with its distance in metres from a query point.
+type StationWithDistance struct {
+	Station
+	Distance float64 `json:"distance"`
+}
+
+// Bbox is a latitude-longitude bounding box.
+type Bbox struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+}
+
+// StationHistory is a single recorded change to a station.
+type StationHistory struct {
+	ID        int       `json:"id"`
+	StationID int       `json:"stationId"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	Before    *Station  `json:"before"`
+	After     *Station  `json:"after"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// User is a local account that can log in to the API.
+type User struct {
+	ID           int            `json:"id"`
+	Username     string         `json:"username"`
+	PasswordHash string         `json:"-"`
+	Role         string         `json:"role"`
+	TagScopes    pq.StringArray `json:"tagScopes"`
+	CreatedAt    time.Time      `json:"createdAt"`
+}
+
+// APIKey is a revocable credential for machine clients. The key itself is never stored.
+type APIKey struct {
+	ID         int            `json:"id"`
+	Name       string         `json:"name"`
+	Prefix     string         `json:"prefix"`
+	KeyHash    string         `json:"-"`
+	Scopes     pq.StringArray `json:"scopes"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	LastUsedAt *time.Time     `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time     `json:"revokedAt,omitempty"`
+}
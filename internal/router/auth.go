@@ -0,0 +1,81 @@
+package router
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"go-https-server/internal/auth"
+	"go-https-server/internal/handler"
+	"go-https-server/internal/logger"
+	"go-https-server/internal/store"
+)
+
+// authMiddleware authenticates the request with either an "Authorization: Bearer"
+// JWT or an API key in the "token" header, and puts the principal on the request
+// context. Requests without credentials pass through anonymously; requests with
+// invalid credentials are rejected.
+func authMiddleware(tokens *auth.Tokens, s *store.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := r.Header.Get("token"); key != "" {
+				apiKey, err := s.AuthenticateAPIKey(r.Context(), auth.HashAPIKey(key))
+				if errors.Is(err, store.ErrNotFound) {
+					handler.RespondWithError(w, http.StatusUnauthorized, "Invalid API key")
+					return
+				}
+				if err != nil {
+					slog.ErrorContext(r.Context(), "could not authenticate API key", "err", err)
+					handler.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+					return
+				}
+				principal := auth.APIKeyPrincipal(apiKey.Name, apiKey.Scopes)
+				logger.SetUser(r.Context(), principal.Username)
+				next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+				return
+			}
+
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok {
+				handler.RespondWithError(w, http.StatusUnauthorized, "Unsupported authorization scheme")
+				return
+			}
+
+			principal, err := tokens.Parse(strings.TrimSpace(token))
+			if err != nil {
+				handler.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
+				return
+			}
+			logger.SetUser(r.Context(), principal.Username)
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// authorize rejects requests whose principal lacks the permission. Routes
+// declared with auth.PermissionNone are open to anonymous callers.
+func authorize(perm auth.Permission, next http.HandlerFunc) http.HandlerFunc {
+	if perm == auth.PermissionNone {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFrom(r.Context())
+		if !ok {
+			handler.RespondWithError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		if !principal.Can(perm) {
+			handler.RespondWithError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		next(w, r)
+	}
+}
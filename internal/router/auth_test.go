@@ -0,0 +1,96 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-https-server/internal/auth"
+)
+
+// routePermission returns the permission required by the API route at path.
+func routePermission(t *testing.T, path string) auth.Permission {
+	t.Helper()
+	for _, rt := range apiRoutes(nil, nil, nil) {
+		if rt.path == path {
+			return rt.permission
+		}
+	}
+	t.Fatalf("no route %s", path)
+	return auth.PermissionNone
+}
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		principal *auth.Principal
+		want      int
+	}{
+		{"anonymous", "/station/qryOfHistory", nil, http.StatusUnauthorized},
+		{"anonymous on a public route", "/station/lst", nil, http.StatusOK},
+		{"viewer", "/station/qryOfHistory", &auth.Principal{Role: auth.RoleViewer}, http.StatusOK},
+		{"viewer writing", "/station/update", &auth.Principal{Role: auth.RoleViewer}, http.StatusForbidden},
+		{"editor purging", "/station/purge", &auth.Principal{Role: auth.RoleEditor}, http.StatusForbidden},
+		{"admin purging", "/station/purge", &auth.Principal{Role: auth.RoleAdmin}, http.StatusOK},
+		{"read key", "/station/qryOfHistory", auth.APIKeyPrincipal("k", []string{auth.ScopeRead}), http.StatusOK},
+		{"read key writing", "/station/update", auth.APIKeyPrincipal("k", []string{auth.ScopeRead}), http.StatusForbidden},
+		{"write key", "/station/update", auth.APIKeyPrincipal("k", []string{auth.ScopeStationWrite}), http.StatusOK},
+		{"write key reading history", "/station/qryOfHistory", auth.APIKeyPrincipal("k", []string{auth.ScopeStationWrite}), http.StatusForbidden},
+		{"key without scopes", "/station/qryOfHistory", auth.APIKeyPrincipal("k", nil), http.StatusForbidden},
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api"+tt.path, nil)
+			if tt.principal != nil {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), tt.principal))
+			}
+			w := httptest.NewRecorder()
+			authorize(routePermission(t, tt.path), ok)(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareBearer(t *testing.T) {
+	tokens, err := auth.NewTokens(auth.TokenConfig{HS256Secret: []byte("secret"), TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := tokens.Issue(&auth.Principal{Username: "alice", Role: auth.RoleEditor})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"valid token", "Bearer " + token, http.StatusOK},
+		{"no credentials", "", http.StatusUnauthorized},
+		{"invalid token", "Bearer " + token + "x", http.StatusUnauthorized},
+		{"other scheme", "Basic YWxpY2U6c2VjcmV0", http.StatusUnauthorized},
+	}
+	// The store is only needed for API keys.
+	h := authMiddleware(tokens, nil)(authorize(routePermission(t, "/station/update"), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/station/update", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
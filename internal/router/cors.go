@@ -0,0 +1,117 @@
+package router
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/gorilla/handlers"
+)
+
+// CORSConfig is the cross-origin policy for everything outside the health and
+// metrics endpoints. AllowedOrigins entries are exact origins such as
+// https://app.example.com, wildcard subdomain patterns such as
+// https://*.example.com, or "*" for any origin.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// corsMiddleware applies cfg and logs preflight requests it rejects.
+func corsMiddleware(cfg CORSConfig) func(http.Handler) http.Handler {
+	opts := []handlers.CORSOption{
+		// The validator makes the handler echo the caller's origin rather than
+		// "*", which browsers require when credentials are allowed.
+		handlers.AllowedOriginValidator(originMatcher(cfg.AllowedOrigins)),
+		handlers.AllowedMethods(cfg.AllowedMethods),
+		handlers.AllowedHeaders(cfg.AllowedHeaders),
+		handlers.MaxAge(int(cfg.MaxAge.Seconds())),
+	}
+	if cfg.AllowCredentials {
+		opts = append(opts, handlers.AllowCredentials())
+	}
+	cors := handlers.CORS(opts...)
+
+	return func(next http.Handler) http.Handler {
+		h := cors(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			if r.Method != http.MethodOptions {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			m := httpsnoop.CaptureMetrics(h, w, r)
+			if reason := preflightRejection(m.Code, w.Header()); reason != "" {
+				slog.WarnContext(r.Context(), "cors preflight rejected",
+					"origin", origin,
+					"reason", reason,
+					"request_method", r.Header.Get("Access-Control-Request-Method"),
+					"request_headers", r.Header.Get("Access-Control-Request-Headers"),
+					"path", r.URL.Path,
+				)
+			}
+		})
+	}
+}
+
+// preflightRejection explains why the CORS handler rejected a preflight, given
+// the status and headers it wrote, or returns "" if it was accepted.
+func preflightRejection(status int, header http.Header) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "missing Access-Control-Request-Method"
+	case http.StatusMethodNotAllowed:
+		return "method not allowed"
+	case http.StatusForbidden:
+		return "header not allowed"
+	}
+	if header.Get("Access-Control-Allow-Origin") == "" {
+		return "origin not allowed"
+	}
+	return ""
+}
+
+// originMatcher returns a function reporting whether an origin matches any of
+// patterns.
+func originMatcher(patterns []string) func(string) bool {
+	return func(origin string) bool {
+		origin = strings.ToLower(origin)
+		for _, p := range patterns {
+			if matchOrigin(strings.ToLower(p), origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// matchOrigin reports whether origin matches pattern, where a pattern of the
+// form scheme://*.domain matches any subdomain of domain, but not domain itself.
+func matchOrigin(pattern, origin string) bool {
+	if pattern == "*" || pattern == origin {
+		return true
+	}
+
+	scheme, host, ok := strings.Cut(pattern, "://*.")
+	if !ok {
+		return false
+	}
+	prefix, suffix := scheme+"://", "."+host
+	if !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+	sub := origin[len(prefix) : len(origin)-len(suffix)]
+	return sub != "" && !strings.ContainsAny(sub, "/:@")
+}
@@ -0,0 +1,44 @@
+package router
+
+import "testing"
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		pattern string
+		origin  string
+		want    bool
+	}{
+		{"*", "https://anything.test", true},
+		{"https://app.example.com", "https://app.example.com", true},
+		{"https://app.example.com", "http://app.example.com", false},
+		{"https://app.example.com", "https://app.example.com:8443", false},
+		{"https://*.example.com", "https://app.example.com", true},
+		{"https://*.example.com", "https://a.b.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://*.example.com", "https://.example.com", false},
+		{"https://*.example.com", "http://app.example.com", false},
+		{"https://*.example.com", "https://app.example.com.evil.test", false},
+		{"https://*.example.com", "https://appexample.com", false},
+		{"https://*.example.com", "https://evil.test/.example.com", false},
+		{"https://*.example.com", "https://user@app.example.com", false},
+		{"https://*.example.com", "https://app.example.com:8443", false},
+		{"https://*.example.com:8443", "https://app.example.com:8443", true},
+	}
+	for _, tt := range tests {
+		if got := matchOrigin(tt.pattern, tt.origin); got != tt.want {
+			t.Errorf("matchOrigin(%q, %q) = %v, want %v", tt.pattern, tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestOriginMatcherIgnoresCase(t *testing.T) {
+	match := originMatcher([]string{"https://*.Example.com", "https://Other.test"})
+	for _, origin := range []string{"https://APP.example.com", "https://other.TEST"} {
+		if !match(origin) {
+			t.Errorf("origin %q was rejected", origin)
+		}
+	}
+	if match("https://example.org") {
+		t.Error("unlisted origin was allowed")
+	}
+}
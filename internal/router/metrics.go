@@ -0,0 +1,28 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/gorilla/mux"
+	"go-https-server/internal/metrics"
+)
+
+// metricsMiddleware records the status and latency of each request, labelled
+// with the matched route template so IDs in paths cannot blow up cardinality.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := httpsnoop.CaptureMetrics(next, w, r)
+		metrics.ObserveHTTP(routeTemplate(r), r.Method, m.Code, m.Duration)
+	})
+}
+
+// routeTemplate returns the path template of the route matched for r.
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tmpl, err := current.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return "unmatched"
+}
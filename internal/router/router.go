@@ -1,40 +1,66 @@
 package router
 
 import (
-	"log"
 	"net/http"
 
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"go-https-server/internal/auth"
 	"go-https-server/internal/handler"
+	"go-https-server/internal/metrics"
+	"go-https-server/internal/store"
 )
 
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("API called: %s %s", r.Method, r.RequestURI)
-		next.ServeHTTP(w, r)
-	})
+// route declares an API endpoint and the permission required to call it.
+type route struct {
+	path       string
+	handler    http.HandlerFunc
+	permission auth.Permission
 }
 
-func New(apiHandler *handler.ApiHandler) http.Handler {
+func New(apiHandler *handler.ApiHandler, authHandler *handler.AuthHandler, seedHandler *handler.SeedHandler, healthHandler *handler.HealthHandler, tokens *auth.Tokens, s *store.Store, cors CORSConfig) http.Handler {
 	r := mux.NewRouter()
 
-	// For development, allow all origins. In production, you should restrict this.
-	corsOrigins := handlers.AllowedOrigins([]string{"*"})
-	corsMethods := handlers.AllowedMethods([]string{"POST", "OPTIONS"})
-	corsHeaders := handlers.AllowedHeaders([]string{"Content-Type", "Authorization", "token", "dt"})
-
-	r.Use(loggingMiddleware)
+	r.Use(tracingMiddleware, metricsMiddleware)
 
 	api := r.PathPrefix("/api").Subrouter()
+	api.Use(authMiddleware(tokens, s))
+
+	for _, rt := range apiRoutes(apiHandler, authHandler, seedHandler) {
+		api.HandleFunc(rt.path, authorize(rt.permission, rt.handler)).Methods(http.MethodPost)
+	}
 
-	api.HandleFunc("/blockedSign/qry", apiHandler.GetBlockedSigns).Methods(http.MethodPost)
-	api.HandleFunc("/station/create", apiHandler.CreateStation).Methods(http.MethodPost)
-	api.HandleFunc("/station/qry", apiHandler.GetStations).Methods(http.MethodPost)
-	api.HandleFunc("/station/qryById", apiHandler.GetStationByID).Methods(http.MethodPost)
-	api.HandleFunc("/station/update", apiHandler.UpdateStation).Methods(http.MethodPost)
-	api.HandleFunc("/station/delete", apiHandler.DeleteStation).Methods(http.MethodPost)
+	// Probes and build info are served outside the API and its CORS wrapper.
+	root := mux.NewRouter()
+	root.Use(requestIDMiddleware, accessLogMiddleware)
+	root.HandleFunc("/healthz", healthHandler.Live).Methods(http.MethodGet)
+	root.HandleFunc("/readyz", healthHandler.Ready).Methods(http.MethodGet)
+	root.HandleFunc("/version", healthHandler.Version).Methods(http.MethodGet)
+	root.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
 
 	// Wrap the router with the CORS middleware
-	return handlers.CORS(corsOrigins, corsMethods, corsHeaders)(r)
+	root.PathPrefix("/").Handler(corsMiddleware(cors)(r))
+	return root
+}
+
+// apiRoutes lists the endpoints served under /api.
+func apiRoutes(apiHandler *handler.ApiHandler, authHandler *handler.AuthHandler, seedHandler *handler.SeedHandler) []route {
+	return []route{
+		{"/auth/login", authHandler.Login, auth.PermissionNone},
+
+		{"/blockedSign/qry", apiHandler.GetBlockedSigns, auth.PermissionNone},
+		{"/blockedSign/qryByBbox", apiHandler.GetBlockedSignsByBbox, auth.PermissionNone},
+		{"/blockedSign/reseed", seedHandler.ReseedBlockedSigns, auth.PermissionBlockedSeed},
+
+		{"/station/create", apiHandler.CreateStation, auth.PermissionStationWrite},
+		{"/station/qry", apiHandler.GetStations, auth.PermissionNone},
+		{"/station/qryByLocation", apiHandler.GetStationsByLocation, auth.PermissionNone},
+		{"/station/qryByBbox", apiHandler.GetStationsByBbox, auth.PermissionNone},
+		{"/station/lst", apiHandler.ListStations, auth.PermissionNone},
+		{"/station/qryById", apiHandler.GetStationByID, auth.PermissionNone},
+		{"/station/qryOfHistory", apiHandler.GetStationHistory, auth.PermissionStationRead},
+		{"/station/update", apiHandler.UpdateStation, auth.PermissionStationWrite},
+		{"/station/delete", apiHandler.DeleteStation, auth.PermissionStationWrite},
+		{"/station/restore", apiHandler.RestoreStation, auth.PermissionStationWrite},
+		{"/station/purge", apiHandler.PurgeStation, auth.PermissionStationPurge},
+	}
 }
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// InFlight tracks the requests currently being served so that those still
+// running when the drain timeout expires can be reported.
+type InFlight struct {
+	mu       sync.Mutex
+	requests map[*http.Request]time.Time
+}
+
+// NewInFlight creates a new InFlight tracker.
+func NewInFlight() *InFlight {
+	return &InFlight{requests: make(map[*http.Request]time.Time)}
+}
+
+// Middleware records each request for the duration of its handler.
+func (f *InFlight) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests[r] = time.Now()
+		f.mu.Unlock()
+
+		defer func() {
+			f.mu.Lock()
+			delete(f.requests, r)
+			f.mu.Unlock()
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LogRemaining logs every request that is still being served.
+func (f *InFlight) LogRemaining() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for r, started := range f.requests {
+		log.Printf("request cut off by shutdown: %s %s from %s, running for %s", r.Method, r.RequestURI, r.RemoteAddr, time.Since(started).Round(time.Millisecond))
+	}
+}
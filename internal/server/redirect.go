@@ -0,0 +1,27 @@
+package server
+
+import (
+	"net"
+	"net/http"
+)
+
+// NewRedirect returns a plain HTTP server on addr that redirects every request
+// to the same host and path over HTTPS on the port of httpsAddr.
+func NewRedirect(addr, httpsAddr string, timeouts Timeouts) *http.Server {
+	_, httpsPort, _ := net.SplitHostPort(httpsAddr)
+
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+
+	return New(addr, redirect, timeouts)
+}
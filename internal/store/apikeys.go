@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-https-server/internal/models"
+)
+
+// CreateAPIKey inserts a new API key. KeyHash, Prefix and Scopes must be set.
+func (s *Store) CreateAPIKey(ctx context.Context, k *models.APIKey) (err error) {
+	ctx, end := s.startOp(ctx, "CreateAPIKey", s.timeouts.Write)
+	defer end(&err)
+
+	if strings.TrimSpace(k.Name) == "" {
+		return fmt.Errorf("%w: API key name is required", ErrInvalidInput)
+	}
+	query := `
+		INSERT INTO api_keys (name, prefix, "keyHash", scopes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, "createdAt"`
+	err = queryRowContext(ctx, s.db, query, k.Name, k.Prefix, k.KeyHash, k.Scopes).Scan(&k.ID, &k.CreatedAt)
+	return translateError(err)
+}
+
+// GetAPIKeys retrieves all API keys, including revoked ones.
+func (s *Store) GetAPIKeys(ctx context.Context) (_ []*models.APIKey, err error) {
+	ctx, end := s.startOp(ctx, "GetAPIKeys", s.timeouts.Read)
+	defer end(&err)
+
+	rows, err := queryContext(ctx, s.db, `SELECT id, name, prefix, scopes, "createdAt", "lastUsedAt", "revokedAt" FROM api_keys ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*models.APIKey, 0)
+	for rows.Next() {
+		var k models.APIKey
+		if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, &k.Scopes, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, &k)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey revokes an API key by its ID. Revoking an already revoked key is a no-op.
+func (s *Store) RevokeAPIKey(ctx context.Context, id int) (err error) {
+	ctx, end := s.startOp(ctx, "RevokeAPIKey", s.timeouts.Write)
+	defer end(&err)
+
+	res, err := execContext(ctx, s.db, `UPDATE api_keys SET "revokedAt" = COALESCE("revokedAt", NOW()) WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res, "API key", id)
+}
+
+// apiKeyTouchInterval is how stale lastUsedAt may get before AuthenticateAPIKey
+// updates it, so busy keys do not cost a write on every request.
+const apiKeyTouchInterval = time.Minute
+
+// AuthenticateAPIKey looks up an unrevoked API key by its hash and records that
+// it was used, at most once per apiKeyTouchInterval.
+func (s *Store) AuthenticateAPIKey(ctx context.Context, keyHash string) (_ *models.APIKey, err error) {
+	ctx, end := s.startOp(ctx, "AuthenticateAPIKey", s.timeouts.Read)
+	defer end(&err)
+
+	query := `
+		SELECT id, name, prefix, scopes, "createdAt", "lastUsedAt", "revokedAt"
+		FROM api_keys
+		WHERE "keyHash" = $1 AND "revokedAt" IS NULL`
+	var k models.APIKey
+	err = queryRowContext(ctx, s.db, query, keyHash).Scan(&k.ID, &k.Name, &k.Prefix, &k.Scopes, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: API key", ErrNotFound)
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	if k.LastUsedAt == nil || time.Since(*k.LastUsedAt) >= apiKeyTouchInterval {
+		// The condition is repeated so concurrent requests update the row once.
+		touch := `
+			UPDATE api_keys SET "lastUsedAt" = NOW()
+			WHERE id = $1 AND ("lastUsedAt" IS NULL OR "lastUsedAt" < NOW() - $2 * INTERVAL '1 second')
+			RETURNING "lastUsedAt"`
+		err := queryRowContext(ctx, s.db, touch, k.ID, apiKeyTouchInterval.Seconds()).Scan(&k.LastUsedAt)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, translateError(err)
+		}
+	}
+	return &k, nil
+}
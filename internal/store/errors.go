@@ -0,0 +1,97 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Errors returned by Store methods. They are usually wrapped with more detail,
+// so callers should test for them with errors.Is.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrInvalidInput = errors.New("invalid input")
+	ErrForbidden    = errors.New("forbidden")
+	ErrTimeout      = errors.New("timeout")
+)
+
+// Postgres error classes that map onto the typed errors above.
+const (
+	pqClassIntegrityViolation = "23"
+	pqClassDataException      = "22"
+	pqCodeQueryCanceled       = "57014"
+)
+
+// constraintMessages holds the client-facing message for each constraint that
+// a request can violate.
+var constraintMessages = map[string]string{
+	"users_username_key":   "username already exists",
+	"api_keys_keyHash_key": "API key already exists",
+}
+
+// pqCodeMessages holds a client-facing message for each Postgres error code
+// that translateError maps without a known constraint.
+var pqCodeMessages = map[pq.ErrorCode]string{
+	"23502": "a required value is missing",
+	"23503": "a referenced record does not exist",
+	"23505": "a record with these values already exists",
+	"23514": "a value is not allowed",
+	"22001": "a value is too long",
+	"22003": "a number is out of range",
+	"22007": "a date or time has an invalid format",
+	"22008": "a date or time is out of range",
+	"22P02": "a value has an invalid format",
+}
+
+// translateError maps driver errors onto the typed store errors.
+// Errors that have no typed equivalent are returned unchanged. Typed errors
+// carry fixed messages rather than the driver's, which name tables,
+// columns and constraints.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: record", ErrNotFound)
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		if pqErr.Code == pqCodeQueryCanceled {
+			return fmt.Errorf("%w: %s", ErrTimeout, pqErr.Message)
+		}
+		switch pqErr.Code.Class() {
+		case pqClassIntegrityViolation:
+			return fmt.Errorf("%w: %s", ErrConflict, pqErrorMessage(pqErr, "conflicts with existing data"))
+		case pqClassDataException:
+			return fmt.Errorf("%w: %s", ErrInvalidInput, pqErrorMessage(pqErr, "invalid value"))
+		}
+	}
+	return err
+}
+
+// pqErrorMessage returns the fixed message for the constraint or code of
+// pqErr, or fallback if neither is known.
+func pqErrorMessage(pqErr *pq.Error, fallback string) string {
+	if msg, ok := constraintMessages[pqErr.Constraint]; ok {
+		return msg
+	}
+	if msg, ok := pqCodeMessages[pqErr.Code]; ok {
+		return msg
+	}
+	return fallback
+}
+
+// expectAffected returns ErrNotFound when an exec touched no rows.
+func expectAffected(res sql.Result, entity string, id int) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s %d", ErrNotFound, entity, id)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestTranslateError(t *testing.T) {
+	other := errors.New("connection reset")
+	tests := []struct {
+		name    string
+		err     error
+		wantIs  error
+		wantMsg string
+	}{
+		{"nil", nil, nil, ""},
+		{"no rows", sql.ErrNoRows, ErrNotFound, "not found: record"},
+		{
+			"known constraint",
+			&pq.Error{Code: "23505", Constraint: "users_username_key", Message: `duplicate key value violates unique constraint "users_username_key"`},
+			ErrConflict, "conflict: username already exists",
+		},
+		{
+			"unknown constraint",
+			&pq.Error{Code: "23505", Constraint: "stations_secret_idx", Message: `duplicate key value violates unique constraint "stations_secret_idx"`},
+			ErrConflict, "conflict: a record with these values already exists",
+		},
+		{
+			"unknown integrity code",
+			&pq.Error{Code: "23P01", Message: `conflicting key value violates exclusion constraint "x"`},
+			ErrConflict, "conflict: conflicts with existing data",
+		},
+		{
+			"data exception",
+			&pq.Error{Code: "22001", Message: "value too long for type character varying(255)"},
+			ErrInvalidInput, "invalid input: a value is too long",
+		},
+		{
+			"unknown data exception",
+			&pq.Error{Code: "22012", Message: "division by zero"},
+			ErrInvalidInput, "invalid input: invalid value",
+		},
+		{"query canceled", &pq.Error{Code: "57014", Message: "canceling statement due to statement timeout"}, ErrTimeout, ""},
+		{"untyped", other, other, "connection reset"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := translateError(tt.err)
+			if tt.wantIs == nil {
+				if got != nil {
+					t.Fatalf("translateError() = %v, want nil", got)
+				}
+				return
+			}
+			if !errors.Is(got, tt.wantIs) {
+				t.Errorf("translateError() = %v, want it to wrap %v", got, tt.wantIs)
+			}
+			if tt.wantMsg != "" && got.Error() != tt.wantMsg {
+				t.Errorf("message = %q, want %q", got.Error(), tt.wantMsg)
+			}
+		})
+	}
+}
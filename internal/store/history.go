@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"go-https-server/internal/auth"
+	"go-https-server/internal/models"
+)
+
+// Actions recorded in station_history.
+const (
+	HistoryActionCreate  = "create"
+	HistoryActionUpdate  = "update"
+	HistoryActionDelete  = "delete"
+	HistoryActionRestore = "restore"
+	HistoryActionPurge   = "purge"
+)
+
+// systemActor is recorded as the author of changes made without an authenticated principal.
+const systemActor = "system"
+
+// actorFrom returns the username of the principal in ctx, or systemActor.
+func actorFrom(ctx context.Context) string {
+	if p, ok := auth.PrincipalFrom(ctx); ok {
+		return p.Username
+	}
+	return systemActor
+}
+
+// authorizeStationTags returns ErrForbidden when the principal in ctx is scoped
+// to tags that the station does not carry.
+func authorizeStationTags(ctx context.Context, tags []string) error {
+	p, ok := auth.PrincipalFrom(ctx)
+	if !ok || p.CanEditTags(tags) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s may only modify stations tagged %v", ErrForbidden, p.Username, p.TagScopes)
+}
+
+// recordStationHistory stores the before and after snapshots of a station change.
+// A nil snapshot is stored as NULL, e.g. before a create or after a purge.
+func recordStationHistory(ctx context.Context, tx *sql.Tx, stationID int, action, actor string, before, after *models.Station) error {
+	beforeJSON, err := snapshotJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := snapshotJSON(after)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO station_history ("stationId", action, actor, before, after)
+		VALUES ($1, $2, $3, $4, $5)`
+	_, err = execContext(ctx, tx, query, stationID, action, actor, beforeJSON, afterJSON)
+	return err
+}
+
+// snapshotJSON encodes a station for a JSONB column. A nil station yields an
+// untyped nil so the driver sends NULL; lib/pq would send a nil []byte as an
+// empty string, which is not valid jsonb.
+func snapshotJSON(st *models.Station) (interface{}, error) {
+	if st == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(st)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// GetStationHistory retrieves the change history of a station, newest first.
+func (s *Store) GetStationHistory(ctx context.Context, stationID int) (_ []*models.StationHistory, err error) {
+	ctx, end := s.startOp(ctx, "GetStationHistory", s.timeouts.Read)
+	defer end(&err)
+
+	query := `
+		SELECT id, "stationId", action, actor, before, after, "changedAt"
+		FROM station_history
+		WHERE "stationId" = $1
+		ORDER BY "changedAt" DESC, id DESC`
+	rows, err := queryContext(ctx, s.db, query, stationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*models.StationHistory, 0)
+	for rows.Next() {
+		var entry models.StationHistory
+		var before, after []byte
+		if err := rows.Scan(&entry.ID, &entry.StationID, &entry.Action, &entry.Actor, &before, &after, &entry.ChangedAt); err != nil {
+			return nil, err
+		}
+		if before != nil {
+			if err := json.Unmarshal(before, &entry.Before); err != nil {
+				return nil, err
+			}
+		}
+		if after != nil {
+			if err := json.Unmarshal(after, &entry.After); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
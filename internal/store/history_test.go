@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"go-https-server/internal/models"
+)
+
+func TestStationHistory(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	st := &models.Station{Name: "history test", Latitude: 22.3, Longitude: 114.2}
+	if err := s.CreateStation(ctx, st); err != nil {
+		t.Fatalf("CreateStation: %v", err)
+	}
+	name := "history test renamed"
+	if _, err := s.UpdateStation(ctx, st.ID, StationPatch{Name: &name}); err != nil {
+		t.Fatalf("UpdateStation: %v", err)
+	}
+	if err := s.PurgeStation(ctx, st.ID); err != nil {
+		t.Fatalf("PurgeStation: %v", err)
+	}
+
+	history, err := s.GetStationHistory(ctx, st.ID)
+	if err != nil {
+		t.Fatalf("GetStationHistory: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("got %d history rows, want 3", len(history))
+	}
+
+	purge, update, create := history[0], history[1], history[2]
+	if create.Action != HistoryActionCreate || create.Before != nil || create.After == nil || create.After.Name != "history test" {
+		t.Errorf("create entry = %+v", create)
+	}
+	if update.Action != HistoryActionUpdate || update.Before == nil || update.Before.Name != "history test" || update.After == nil || update.After.Name != name {
+		t.Errorf("update entry = %+v", update)
+	}
+	if purge.Action != HistoryActionPurge || purge.Before == nil || purge.Before.Name != name || purge.After != nil {
+		t.Errorf("purge entry = %+v", purge)
+	}
+	for _, entry := range history {
+		if entry.Actor != systemActor {
+			t.Errorf("%s entry actor = %q, want %q", entry.Action, entry.Actor, systemActor)
+		}
+	}
+}
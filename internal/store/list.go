@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"go-https-server/internal/models"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// maxPage is the highest page a list request may ask for. It keeps the OFFSET
+// of the query far from overflowing.
+const maxPage = 1_000_000
+
+// SortField describes a single sort key of a list request.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ListOptions holds the sorting and paging parameters of a list request.
+type ListOptions struct {
+	Sort     []SortField
+	Page     int
+	PageSize int
+}
+
+// normalize applies the default page and page size and rejects invalid values.
+func (o *ListOptions) normalize() error {
+	if o.Page == 0 {
+		o.Page = 1
+	}
+	if o.PageSize == 0 {
+		o.PageSize = defaultPageSize
+	}
+	if o.Page < 1 || o.Page > maxPage {
+		return fmt.Errorf("%w: page must be between 1 and %d", ErrInvalidInput, maxPage)
+	}
+	if o.PageSize < 1 || o.PageSize > maxPageSize {
+		return fmt.Errorf("%w: pageSize must be between 1 and %d", ErrInvalidInput, maxPageSize)
+	}
+	return nil
+}
+
+// StationFilter narrows the stations returned by ListStations. Zero values are ignored.
+type StationFilter struct {
+	NameContains string
+	TagsAny      []string
+	TagsAll      []string
+	IsActive     *bool
+	CreatedBy    string
+	CreatedFrom  *time.Time
+	CreatedTo    *time.Time
+}
+
+// stationSortColumns maps the sortable API field names to their columns.
+var stationSortColumns = map[string]string{
+	"id":        "id",
+	"name":      "name",
+	"createdBy": `"createdBy"`,
+	"createdAt": `"createdAt"`,
+	"updatedAt": `"updatedAt"`,
+	"isActive":  `"isActive"`,
+}
+
+// likeEscaper escapes the LIKE wildcards in a literal search term, for use with ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListStations retrieves one page of stations matching the filter, together with
+// the total number of matching stations.
+func (s *Store) ListStations(ctx context.Context, filter StationFilter, opts ListOptions) (_ []*models.Station, _ int, err error) {
+	ctx, end := s.startOp(ctx, "ListStations", s.timeouts.Read)
+	defer end(&err)
+
+	if err := opts.normalize(); err != nil {
+		return nil, 0, err
+	}
+	q, err := stationListQuery(filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	countQuery, countArgs := q.CountSQL()
+	if err := queryRowContext(ctx, s.db, countQuery, countArgs...).Scan(&total); err != nil {
+		return nil, 0, translateError(err)
+	}
+
+	query, args := q.SQL()
+	rows, err := queryContext(ctx, s.db, query, args...)
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+	defer rows.Close()
+
+	stations := make([]*models.Station, 0)
+	for rows.Next() {
+		var station models.Station
+		if err := rows.Scan(&station.ID, &station.Name, &station.Latitude, &station.Longitude, &station.CreatedBy, &station.CreatedAt, &station.UpdatedAt, &station.IsActive, &station.Tags); err != nil {
+			return nil, 0, translateError(err)
+		}
+		stations = append(stations, &station)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, translateError(err)
+	}
+	return stations, total, nil
+}
+
+// stationListQuery builds the query for one page of ListStations from
+// normalized options.
+func stationListQuery(filter StationFilter, opts ListOptions) (*selectQuery, error) {
+	q := newSelectQuery(`id, name, ST_Y(location::geometry) AS latitude, ST_X(location::geometry) AS longitude, "createdBy", "createdAt", "updatedAt", "isActive", tags`, "stations")
+	if filter.NameContains != "" {
+		q.Where(`name ILIKE '%' || ? || '%' ESCAPE '\'`, likeEscaper.Replace(filter.NameContains))
+	}
+	if len(filter.TagsAny) > 0 {
+		q.Where("tags && ?", pq.Array(filter.TagsAny))
+	}
+	if len(filter.TagsAll) > 0 {
+		q.Where("tags @> ?", pq.Array(filter.TagsAll))
+	}
+	if filter.IsActive != nil {
+		q.Where(`"isActive" = ?`, *filter.IsActive)
+	}
+	if filter.CreatedBy != "" {
+		q.Where(`"createdBy" = ?`, filter.CreatedBy)
+	}
+	if filter.CreatedFrom != nil {
+		q.Where(`"createdAt" >= ?`, *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		q.Where(`"createdAt" < ?`, *filter.CreatedTo)
+	}
+
+	for _, sf := range opts.Sort {
+		column, ok := stationSortColumns[sf.Field]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown sort field %q", ErrInvalidInput, sf.Field)
+		}
+		q.OrderBy(column, sf.Desc)
+	}
+	// Always finish with a unique key so pages are stable.
+	q.OrderBy("id", false)
+	q.Paginate(opts.Page, opts.PageSize)
+	return q, nil
+}
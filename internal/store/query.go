@@ -0,0 +1,84 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// selectQuery incrementally builds a parameterised SELECT statement.
+// Conditions are written with "?" placeholders, which are rewritten to
+// Postgres positional parameters ($1, $2, ...) in the order they are added.
+type selectQuery struct {
+	columns string
+	from    string
+	where   []string
+	args    []interface{}
+	orderBy []string
+	limit   int
+	offset  int
+}
+
+func newSelectQuery(columns, from string) *selectQuery {
+	return &selectQuery{columns: columns, from: from}
+}
+
+// Where adds a condition that is ANDed with the existing ones.
+func (q *selectQuery) Where(cond string, args ...interface{}) *selectQuery {
+	var b strings.Builder
+	argIdx := 0
+	for _, r := range cond {
+		if r == '?' && argIdx < len(args) {
+			q.args = append(q.args, args[argIdx])
+			argIdx++
+			fmt.Fprintf(&b, "$%d", len(q.args))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	q.where = append(q.where, b.String())
+	return q
+}
+
+// OrderBy appends a sort expression. expr must come from a trusted whitelist.
+func (q *selectQuery) OrderBy(expr string, desc bool) *selectQuery {
+	if desc {
+		expr += " DESC"
+	} else {
+		expr += " ASC"
+	}
+	q.orderBy = append(q.orderBy, expr)
+	return q
+}
+
+// Paginate limits the result to the given 1-based page.
+func (q *selectQuery) Paginate(page, pageSize int) *selectQuery {
+	q.limit = pageSize
+	q.offset = (page - 1) * pageSize
+	return q
+}
+
+func (q *selectQuery) whereClause() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.where, " AND ")
+}
+
+// SQL returns the statement and its arguments.
+func (q *selectQuery) SQL() (string, []interface{}) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "SELECT %s FROM %s%s", q.columns, q.from, q.whereClause())
+	if len(q.orderBy) > 0 {
+		b.WriteString(" ORDER BY " + strings.Join(q.orderBy, ", "))
+	}
+	if q.limit > 0 {
+		fmt.Fprintf(&b, " LIMIT %d OFFSET %d", q.limit, q.offset)
+	}
+	return b.String(), q.args
+}
+
+// CountSQL returns a statement counting every row matching the conditions,
+// ignoring ordering and pagination.
+func (q *selectQuery) CountSQL() (string, []interface{}) {
+	return fmt.Sprintf("SELECT COUNT(*) FROM %s%s", q.from, q.whereClause()), q.args
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestSelectQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		build     func(q *selectQuery)
+		wantSQL   string
+		wantCount string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "no conditions",
+			build:     func(q *selectQuery) {},
+			wantSQL:   "SELECT id FROM t",
+			wantCount: "SELECT COUNT(*) FROM t",
+		},
+		{
+			name: "placeholders are numbered across Where calls",
+			build: func(q *selectQuery) {
+				q.Where("a = ?", 1)
+				q.Where("b BETWEEN ? AND ?", 2, 3)
+				q.Where("c IS NULL")
+				q.Where("d = ?", "x")
+			},
+			wantSQL:   "SELECT id FROM t WHERE a = $1 AND b BETWEEN $2 AND $3 AND c IS NULL AND d = $4",
+			wantCount: "SELECT COUNT(*) FROM t WHERE a = $1 AND b BETWEEN $2 AND $3 AND c IS NULL AND d = $4",
+			wantArgs:  []interface{}{1, 2, 3, "x"},
+		},
+		{
+			name: "question marks beyond the arguments are kept",
+			build: func(q *selectQuery) {
+				q.Where("a = ?", 1)
+				q.Where("b ? 'key'")
+			},
+			wantSQL:   "SELECT id FROM t WHERE a = $1 AND b ? 'key'",
+			wantCount: "SELECT COUNT(*) FROM t WHERE a = $1 AND b ? 'key'",
+			wantArgs:  []interface{}{1},
+		},
+		{
+			name: "count ignores ordering and pagination",
+			build: func(q *selectQuery) {
+				q.Where("a = ?", 1)
+				q.OrderBy("name", true)
+				q.OrderBy("id", false)
+				q.Paginate(3, 20)
+			},
+			wantSQL:   "SELECT id FROM t WHERE a = $1 ORDER BY name DESC, id ASC LIMIT 20 OFFSET 40",
+			wantCount: "SELECT COUNT(*) FROM t WHERE a = $1",
+			wantArgs:  []interface{}{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newSelectQuery("id", "t")
+			tt.build(q)
+			sql, args := q.SQL()
+			countSQL, countArgs := q.CountSQL()
+			if sql != tt.wantSQL {
+				t.Errorf("SQL() = %q, want %q", sql, tt.wantSQL)
+			}
+			if countSQL != tt.wantCount {
+				t.Errorf("CountSQL() = %q, want %q", countSQL, tt.wantCount)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) || !reflect.DeepEqual(countArgs, tt.wantArgs) {
+				t.Errorf("args = %v, count args = %v, want %v for both", args, countArgs, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestStationListQuery(t *testing.T) {
+	active := true
+	q, err := stationListQuery(
+		StationFilter{NameContains: "50%_off", TagsAny: []string{"bus"}, IsActive: &active},
+		ListOptions{Page: 2, PageSize: 10, Sort: []SortField{{Field: "createdAt", Desc: true}, {Field: "name"}}},
+	)
+	if err != nil {
+		t.Fatalf("stationListQuery: %v", err)
+	}
+
+	sql, args := q.SQL()
+	wantSQL := `SELECT id, name, ST_Y(location::geometry) AS latitude, ST_X(location::geometry) AS longitude, "createdBy", "createdAt", "updatedAt", "isActive", tags FROM stations` +
+		` WHERE name ILIKE '%' || $1 || '%' ESCAPE '\' AND tags && $2 AND "isActive" = $3` +
+		` ORDER BY "createdAt" DESC, name ASC, id ASC LIMIT 10 OFFSET 10`
+	if sql != wantSQL {
+		t.Errorf("SQL() =\n %s\nwant\n %s", sql, wantSQL)
+	}
+	wantArgs := []interface{}{`50\%\_off`, pq.Array([]string{"bus"}), true}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+
+	q, err = stationListQuery(StationFilter{}, ListOptions{Page: 1, PageSize: 20})
+	if err != nil {
+		t.Fatalf("stationListQuery: %v", err)
+	}
+	if sql, _ := q.SQL(); !strings.HasSuffix(sql, " FROM stations ORDER BY id ASC LIMIT 20 OFFSET 0") {
+		t.Errorf("unsorted query %q should be ordered by id only", sql)
+	}
+
+	if _, err := stationListQuery(StationFilter{}, ListOptions{Page: 1, PageSize: 20, Sort: []SortField{{Field: "location"}}}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("unknown sort field: err = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestListOptionsNormalize(t *testing.T) {
+	tests := []struct {
+		opts    ListOptions
+		want    ListOptions
+		wantErr bool
+	}{
+		{opts: ListOptions{}, want: ListOptions{Page: 1, PageSize: defaultPageSize}},
+		{opts: ListOptions{Page: maxPage, PageSize: maxPageSize}, want: ListOptions{Page: maxPage, PageSize: maxPageSize}},
+		{opts: ListOptions{Page: -1}, wantErr: true},
+		{opts: ListOptions{Page: maxPage + 1}, wantErr: true},
+		{opts: ListOptions{PageSize: -1}, wantErr: true},
+		{opts: ListOptions{PageSize: maxPageSize + 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		opts := tt.opts
+		err := opts.normalize()
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("normalize(%+v) = %v, want ErrInvalidInput", tt.opts, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(opts, tt.want) {
+			t.Errorf("normalize(%+v) = %+v, %v; want %+v", tt.opts, opts, err, tt.want)
+		}
+	}
+}
@@ -1,56 +1,125 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"go-https-server/internal/models"
 )
 
+// Timeouts bounds how long Store methods may run. Zero disables the bound.
+type Timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+}
+
 // Store handles all database operations.
 type Store struct {
-	db *sql.DB
+	db       *sql.DB
+	timeouts Timeouts
 }
 
 // New creates a new Store.
-func New(db *sql.DB) *Store {
-	return &Store{db: db}
+func New(db *sql.DB, timeouts Timeouts) *Store {
+	return &Store{db: db, timeouts: timeouts}
 }
 
 // GetBlockedSigns retrieves all blocked signs from the database.
-func (s *Store) GetBlockedSigns() ([]*models.BlockedSign, error) {
-	rows, err := s.db.Query("SELECT id, ST_Y(location::geometry) AS latitude, ST_X(location::geometry) AS longitude FROM blockedSigns")
+func (s *Store) GetBlockedSigns(ctx context.Context) (_ []*models.BlockedSign, err error) {
+	ctx, end := s.startOp(ctx, "GetBlockedSigns", s.timeouts.Read)
+	defer end(&err)
+
+	rows, err := queryContext(ctx, s.db, "SELECT "+blockedSignColumns+" FROM blockedSigns")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanBlockedSigns(rows)
+}
+
+// GetBlockedSignsByBbox retrieves the blocked signs inside the bounding box.
+// A limit of zero or less returns every match.
+func (s *Store) GetBlockedSignsByBbox(ctx context.Context, bbox models.Bbox, limit int) (_ []*models.BlockedSign, err error) {
+	ctx, end := s.startOp(ctx, "GetBlockedSignsByBbox", s.timeouts.Read)
+	defer end(&err)
+
+	query := `
+		SELECT ` + blockedSignColumns + `
+		FROM blockedSigns
+		WHERE location && ST_MakeEnvelope($1, $2, $3, $4, 4326)::geography
+		ORDER BY id ASC
+		LIMIT $5`
+	rows, err := queryContext(ctx, s.db, query, bbox.MinLongitude, bbox.MinLatitude, bbox.MaxLongitude, bbox.MaxLatitude, nullLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanBlockedSigns(rows)
+}
+
+// blockedSignColumns selects the columns read by scanBlockedSigns.
+const blockedSignColumns = `id,
+	ST_Y(ST_PointOnSurface(location::geometry)) AS latitude, ST_X(ST_PointOnSurface(location::geometry)) AS longitude,
+	ST_AsGeoJSON(location),
+	COALESCE(name, ''), COALESCE(description, ''), COALESCE("styleUrl", ''), "folderPath", properties, COALESCE("sourceFile", '')`
+
+func scanBlockedSigns(rows *sql.Rows) ([]*models.BlockedSign, error) {
 	signs := make([]*models.BlockedSign, 0)
 	for rows.Next() {
 		var sign models.BlockedSign
-		if err := rows.Scan(&sign.ID, &sign.Latitude, &sign.Longitude); err != nil {
+		var properties []byte
+		if err := rows.Scan(&sign.ID, &sign.Latitude, &sign.Longitude, &sign.Geometry, &sign.Name, &sign.Description, &sign.StyleURL, &sign.FolderPath, &properties, &sign.SourceFile); err != nil {
 			return nil, err
 		}
+		if err := json.Unmarshal(properties, &sign.Properties); err != nil {
+			return nil, fmt.Errorf("could not decode properties of blocked sign %d: %w", sign.ID, err)
+		}
 		signs = append(signs, &sign)
 	}
-	return signs, nil
+	return signs, rows.Err()
 }
 
 // CreateStationPoint inserts a new station point into the database.
-func (s *Store) CreateStation(st *models.Station) error {
+func (s *Store) CreateStation(ctx context.Context, st *models.Station) (err error) {
+	ctx, end := s.startOp(ctx, "CreateStation", s.timeouts.Write)
+	defer end(&err)
+
 	query := `
 		INSERT INTO stations (name, location, "createdBy", "isActive", tags)
 		VALUES ($1, ST_SetSRID(ST_MakePoint($2, $3), 4326), $4, $5, $6)
 		RETURNING id, "createdAt"`
-	// For simplicity, created_by is hardcoded. In a real app, this would come from auth.
-	st.CreatedBy = "Current User"
+	if strings.TrimSpace(st.Name) == "" {
+		return fmt.Errorf("%w: station name is required", ErrInvalidInput)
+	}
+	if err := authorizeStationTags(ctx, st.Tags); err != nil {
+		return err
+	}
+
+	actor := actorFrom(ctx)
+	st.CreatedBy = actor
 	st.IsActive = true
-	return s.db.QueryRow(query, st.Name, st.Longitude, st.Latitude, st.CreatedBy, st.IsActive, st.Tags).Scan(&st.ID, &st.CreatedAt)
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := queryRowContext(ctx, tx, query, st.Name, st.Longitude, st.Latitude, st.CreatedBy, st.IsActive, st.Tags).Scan(&st.ID, &st.CreatedAt); err != nil {
+			return translateError(err)
+		}
+		return recordStationHistory(ctx, tx, st.ID, HistoryActionCreate, actor, nil, st)
+	})
 }
 
-// GetStationPoints retrieves all station points from the database.
-func (s *Store) GetStations() ([]*models.Station, error) {
-	rows, err := s.db.Query(`SELECT id, name, ST_Y(location::geometry) AS latitude, ST_X(location::geometry) AS longitude, "createdBy", "createdAt", "updatedAt", "isActive", tags FROM stations ORDER BY id ASC`)
+// GetStations retrieves station points from the database.
+// Deactivated stations are only included when includeInactive is set.
+func (s *Store) GetStations(ctx context.Context, includeInactive bool) (_ []*models.Station, err error) {
+	ctx, end := s.startOp(ctx, "GetStations", s.timeouts.Read)
+	defer end(&err)
+
+	rows, err := queryContext(ctx, s.db, `SELECT id, name, ST_Y(location::geometry) AS latitude, ST_X(location::geometry) AS longitude, "createdBy", "createdAt", "updatedAt", "isActive", tags FROM stations WHERE $1 OR "isActive" ORDER BY id ASC`, includeInactive)
 	if err != nil {
 		return nil, err
 	}
@@ -67,11 +136,71 @@ func (s *Store) GetStations() ([]*models.Station, error) {
 	return stations, nil
 }
 
+// GetStationsByLocation retrieves the stations within radius metres of the given point, nearest first.
+func (s *Store) GetStationsByLocation(ctx context.Context, latitude, longitude, radius float64) (_ []*models.StationWithDistance, err error) {
+	ctx, end := s.startOp(ctx, "GetStationsByLocation", s.timeouts.Read)
+	defer end(&err)
+
+	query := `
+		SELECT id, name, ST_Y(location::geometry) AS latitude, ST_X(location::geometry) AS longitude, "createdBy", "createdAt", "updatedAt", "isActive", tags,
+			ST_Distance(location, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography) AS distance
+		FROM stations
+		WHERE "isActive" AND ST_DWithin(location, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, $3)
+		ORDER BY distance ASC, id ASC`
+	rows, err := queryContext(ctx, s.db, query, longitude, latitude, radius)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stations := make([]*models.StationWithDistance, 0)
+	for rows.Next() {
+		var station models.StationWithDistance
+		if err := rows.Scan(&station.ID, &station.Name, &station.Latitude, &station.Longitude, &station.CreatedBy, &station.CreatedAt, &station.UpdatedAt, &station.IsActive, &station.Tags, &station.Distance); err != nil {
+			return nil, err
+		}
+		stations = append(stations, &station)
+	}
+	return stations, rows.Err()
+}
+
+// GetStationsByBbox retrieves the stations inside the bounding box.
+// A limit of zero or less returns every match.
+func (s *Store) GetStationsByBbox(ctx context.Context, bbox models.Bbox, limit int) (_ []*models.Station, err error) {
+	ctx, end := s.startOp(ctx, "GetStationsByBbox", s.timeouts.Read)
+	defer end(&err)
+
+	query := `
+		SELECT id, name, ST_Y(location::geometry) AS latitude, ST_X(location::geometry) AS longitude, "createdBy", "createdAt", "updatedAt", "isActive", tags
+		FROM stations
+		WHERE "isActive" AND location && ST_MakeEnvelope($1, $2, $3, $4, 4326)::geography
+		ORDER BY id ASC
+		LIMIT $5`
+	rows, err := queryContext(ctx, s.db, query, bbox.MinLongitude, bbox.MinLatitude, bbox.MaxLongitude, bbox.MaxLatitude, nullLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stations := make([]*models.Station, 0)
+	for rows.Next() {
+		var station models.Station
+		if err := rows.Scan(&station.ID, &station.Name, &station.Latitude, &station.Longitude, &station.CreatedBy, &station.CreatedAt, &station.UpdatedAt, &station.IsActive, &station.Tags); err != nil {
+			return nil, err
+		}
+		stations = append(stations, &station)
+	}
+	return stations, rows.Err()
+}
+
 // GetStationByID retrieves a single station by its ID.
-func (s *Store) GetStationByID(id int) (*models.Station, error) {
+func (s *Store) GetStationByID(ctx context.Context, id int) (_ *models.Station, err error) {
+	ctx, end := s.startOp(ctx, "GetStationByID", s.timeouts.Read)
+	defer end(&err)
+
 	var station models.Station
 	query := `SELECT id, name, ST_Y(location::geometry) AS latitude, ST_X(location::geometry) AS longitude, "createdBy", "createdAt", "updatedAt", "isActive", tags FROM stations WHERE id = $1`
-	err := s.db.QueryRow(query, id).Scan(&station.ID, &station.Name, &station.Latitude, &station.Longitude, &station.CreatedBy, &station.CreatedAt, &station.UpdatedAt, &station.IsActive, &station.Tags)
+	err = queryRowContext(ctx, s.db, query, id).Scan(&station.ID, &station.Name, &station.Latitude, &station.Longitude, &station.CreatedBy, &station.CreatedAt, &station.UpdatedAt, &station.IsActive, &station.Tags)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Not found
@@ -81,19 +210,156 @@ func (s *Store) GetStationByID(id int) (*models.Station, error) {
 	return &station, nil
 }
 
-// DeleteStation deletes a station from the database by its ID.
-func (s *Store) DeleteStation(id int) error {
+// DeleteStation deactivates a station by its ID. The row is kept so it can be restored.
+func (s *Store) DeleteStation(ctx context.Context, id int) (err error) {
+	ctx, end := s.startOp(ctx, "DeleteStation", s.timeouts.Write)
+	defer end(&err)
+
+	return s.setStationActive(ctx, id, false, HistoryActionDelete)
+}
+
+// RestoreStation reactivates a previously deleted station by its ID.
+func (s *Store) RestoreStation(ctx context.Context, id int) (err error) {
+	ctx, end := s.startOp(ctx, "RestoreStation", s.timeouts.Write)
+	defer end(&err)
+
+	return s.setStationActive(ctx, id, true, HistoryActionRestore)
+}
+
+func (s *Store) setStationActive(ctx context.Context, id int, active bool, action string) error {
+	query := `
+		UPDATE stations SET "isActive" = $1, "updatedAt" = $2 WHERE id = $3
+		RETURNING id, name, ST_Y(location::geometry) AS latitude, ST_X(location::geometry) AS longitude, "createdBy", "createdAt", "updatedAt", "isActive", tags`
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockStation(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := authorizeStationTags(ctx, before.Tags); err != nil {
+			return err
+		}
+
+		var after models.Station
+		if err := queryRowContext(ctx, tx, query, active, time.Now(), id).Scan(&after.ID, &after.Name, &after.Latitude, &after.Longitude, &after.CreatedBy, &after.CreatedAt, &after.UpdatedAt, &after.IsActive, &after.Tags); err != nil {
+			return translateError(err)
+		}
+		return recordStationHistory(ctx, tx, id, action, actorFrom(ctx), before, &after)
+	})
+}
+
+// PurgeStation permanently removes a station from the database by its ID.
+// Its history is kept.
+func (s *Store) PurgeStation(ctx context.Context, id int) (err error) {
+	ctx, end := s.startOp(ctx, "PurgeStation", s.timeouts.Write)
+	defer end(&err)
+
 	query := "DELETE FROM stations WHERE id = $1"
-	_, err := s.db.Exec(query, id)
-	return err
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockStation(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := authorizeStationTags(ctx, before.Tags); err != nil {
+			return err
+		}
+
+		if _, err := execContext(ctx, tx, query, id); err != nil {
+			return translateError(err)
+		}
+		return recordStationHistory(ctx, tx, id, HistoryActionPurge, actorFrom(ctx), before, nil)
+	})
+}
+
+// StationPatch holds the fields of a partial station update. Nil fields are left unchanged.
+type StationPatch struct {
+	Name      *string
+	Latitude  *float64
+	Longitude *float64
+	IsActive  *bool
+	Tags      *[]string
 }
 
-// UpdateStation updates an existing station in the database.
-func (s *Store) UpdateStation(st *models.Station) error {
+// UpdateStation applies a partial update to an existing station and returns the updated row.
+func (s *Store) UpdateStation(ctx context.Context, id int, patch StationPatch) (_ *models.Station, err error) {
+	ctx, end := s.startOp(ctx, "UpdateStation", s.timeouts.Write)
+	defer end(&err)
+
+	if patch.Name != nil && strings.TrimSpace(*patch.Name) == "" {
+		return nil, fmt.Errorf("%w: station name must not be empty", ErrInvalidInput)
+	}
+
 	query := `
 		UPDATE stations
-		SET name = $1, tags = $2, "updatedAt" = $3
-		WHERE id = $4
+		SET name = COALESCE($1::varchar, name),
+			location = ST_SetSRID(ST_MakePoint(
+				COALESCE($2::double precision, ST_X(location::geometry)),
+				COALESCE($3::double precision, ST_Y(location::geometry))), 4326)::geography,
+			"isActive" = COALESCE($4::boolean, "isActive"),
+			tags = CASE WHEN $5::boolean THEN $6::text[] ELSE tags END,
+			"updatedAt" = $7
+		WHERE id = $8
 		RETURNING id, name, ST_Y(location::geometry) AS latitude, ST_X(location::geometry) AS longitude, "createdBy", "createdAt", "updatedAt", "isActive", tags`
-	return s.db.QueryRow(query, st.Name, st.Tags, time.Now(), st.ID).Scan(&st.ID, &st.Name, &st.Latitude, &st.Longitude, &st.CreatedBy, &st.CreatedAt, &st.UpdatedAt, &st.IsActive, &st.Tags)
+
+	var tags interface{}
+	if patch.Tags != nil {
+		tags = pq.Array(*patch.Tags)
+	}
+
+	var st models.Station
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockStation(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := authorizeStationTags(ctx, before.Tags); err != nil {
+			return err
+		}
+		if patch.Tags != nil {
+			if err := authorizeStationTags(ctx, *patch.Tags); err != nil {
+				return err
+			}
+		}
+
+		if err := queryRowContext(ctx, tx, query, patch.Name, patch.Longitude, patch.Latitude, patch.IsActive, patch.Tags != nil, tags, time.Now(), id).Scan(&st.ID, &st.Name, &st.Latitude, &st.Longitude, &st.CreatedBy, &st.CreatedAt, &st.UpdatedAt, &st.IsActive, &st.Tags); err != nil {
+			return translateError(err)
+		}
+		return recordStationHistory(ctx, tx, id, HistoryActionUpdate, actorFrom(ctx), before, &st)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// withTx runs fn inside a transaction, committing if it returns nil and rolling back otherwise.
+func (s *Store) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockStation reads a station and locks its row for the rest of the transaction.
+func lockStation(ctx context.Context, tx *sql.Tx, id int) (*models.Station, error) {
+	var st models.Station
+	query := `SELECT id, name, ST_Y(location::geometry) AS latitude, ST_X(location::geometry) AS longitude, "createdBy", "createdAt", "updatedAt", "isActive", tags FROM stations WHERE id = $1 FOR UPDATE`
+	err := queryRowContext(ctx, tx, query, id).Scan(&st.ID, &st.Name, &st.Latitude, &st.Longitude, &st.CreatedBy, &st.CreatedAt, &st.UpdatedAt, &st.IsActive, &st.Tags)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: station %d", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// nullLimit maps a non-positive limit to NULL, which Postgres treats as LIMIT ALL.
+func nullLimit(limit int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(limit), Valid: limit > 0}
 }
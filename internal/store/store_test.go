@@ -0,0 +1,28 @@
+package store
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"go-https-server/internal/database"
+)
+
+// newTestStore connects to the database named by TEST_DATABASE_URL and
+// migrates it, skipping the test when the variable is unset.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return New(db, Timeouts{})
+}
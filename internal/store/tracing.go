@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("go-https-server/internal/store")
+
+var dbSystem = attribute.String("db.system", "postgresql")
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// startOp starts the span covering a Store method and bounds the method by
+// timeout, if positive. The returned function must be deferred with a pointer
+// to the method's error: it turns an exceeded deadline into ErrTimeout, records
+// the error on the span and releases the timeout.
+func (s *Store) startOp(ctx context.Context, method string, timeout time.Duration) (context.Context, func(*error)) {
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	ctx, span := tracer.Start(ctx, "Store."+method, trace.WithAttributes(dbSystem))
+
+	return ctx, func(errp *error) {
+		if *errp != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && !errors.Is(*errp, ErrTimeout) {
+			*errp = fmt.Errorf("%w: %s exceeded %s: %v", ErrTimeout, method, timeout, *errp)
+		}
+		endSpan(span, *errp)
+		cancel()
+	}
+}
+
+// endSpan records err on the span and ends it. Not-found results are expected
+// outcomes and are not marked as errors.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startStatementSpan starts a client span for a single SQL statement.
+func startStatementSpan(ctx context.Context, query string) trace.Span {
+	_, span := tracer.Start(ctx, "db.query",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(dbSystem, attribute.String("db.statement", query)))
+	return span
+}
+
+func queryContext(ctx context.Context, q queryer, query string, args ...interface{}) (*sql.Rows, error) {
+	span := startStatementSpan(ctx, query)
+	rows, err := q.QueryContext(ctx, query, args...)
+	endSpan(span, err)
+	return rows, err
+}
+
+// queryRowContext runs a single-row query. Errors surface from Scan and are
+// recorded on the enclosing method span rather than the statement span.
+func queryRowContext(ctx context.Context, q queryer, query string, args ...interface{}) *sql.Row {
+	span := startStatementSpan(ctx, query)
+	row := q.QueryRowContext(ctx, query, args...)
+	endSpan(span, row.Err())
+	return row
+}
+
+func execContext(ctx context.Context, q queryer, query string, args ...interface{}) (sql.Result, error) {
+	span := startStatementSpan(ctx, query)
+	res, err := q.ExecContext(ctx, query, args...)
+	endSpan(span, err)
+	return res, err
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"go-https-server/internal/models"
+)
+
+// CreateUser inserts a new user with an already hashed password.
+func (s *Store) CreateUser(ctx context.Context, u *models.User) (err error) {
+	ctx, end := s.startOp(ctx, "CreateUser", s.timeouts.Write)
+	defer end(&err)
+
+	if strings.TrimSpace(u.Username) == "" {
+		return fmt.Errorf("%w: username is required", ErrInvalidInput)
+	}
+	query := `
+		INSERT INTO users (username, "passwordHash", role, "tagScopes")
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, "createdAt"`
+	err = queryRowContext(ctx, s.db, query, u.Username, u.PasswordHash, u.Role, u.TagScopes).Scan(&u.ID, &u.CreatedAt)
+	return translateError(err)
+}
+
+// GetUserByUsername retrieves a user by username.
+func (s *Store) GetUserByUsername(ctx context.Context, username string) (_ *models.User, err error) {
+	ctx, end := s.startOp(ctx, "GetUserByUsername", s.timeouts.Read)
+	defer end(&err)
+
+	var u models.User
+	query := `SELECT id, username, "passwordHash", role, "tagScopes", "createdAt" FROM users WHERE username = $1`
+	err = queryRowContext(ctx, s.db, query, username).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.TagScopes, &u.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: user %q", ErrNotFound, username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}